@@ -1,18 +1,60 @@
 package apiConfig
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
 	"sync/atomic"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/blobstore"
 	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/entitlement"
+	"github.com/samuelhamann/chirpy/internal/mailer"
+	"github.com/samuelhamann/chirpy/internal/password"
+	"github.com/samuelhamann/chirpy/internal/trending"
+	"github.com/samuelhamann/chirpy/internal/webauthn"
 )
 
 type ApiConfig struct {
 	FileserverHits atomic.Int32
+	DB *sql.DB
 	Database *database.Queries
 	Platform string
-	JWTSecret string
+	// Keys signs and verifies access tokens.
+	Keys *auth.Keyring
+	// Passwords hashes account passwords, and PasswordPolicy decides which
+	// new ones are accepted.
+	Passwords *auth.PasswordHasher
+	PasswordPolicy password.Policy
+	// PolkaKey is the secret Polka signs webhook deliveries with.
 	PolkaKey string
+	// AdminKey authorizes the /admin endpoints that change state. They are
+	// disabled when it is empty.
+	AdminKey string
+	// Entitlements sets the limits of each tier, such as how long a chirp
+	// may be and whether it can be edited.
+	Entitlements entitlement.Table
+	Trending *trending.Tracker
+	// Media stores uploaded attachments; MediaSigningKey signs their
+	// download URLs and MediaMaxBytes caps the size of a single upload.
+	Media blobstore.BlobStore
+	MediaSigningKey []byte
+	MediaMaxBytes int64
+	// Mailer sends account emails from MailFrom; links in them point at
+	// PublicURL.
+	Mailer mailer.Mailer
+	MailFrom string
+	PublicURL string
+	// RequireVerifiedEmail stops users posting chirps until they have
+	// verified their email address.
+	RequireVerifiedEmail bool
+	// WebAuthn is the relying party passkeys are registered with.
+	WebAuthn webauthn.RelyingParty
+	// ResourceServers are the OAuth clients that may introspect any access
+	// token. Other clients only learn about tokens issued to themselves.
+	ResourceServers []uuid.UUID
 }
 
 func (cfg *ApiConfig) HandlerReset(w http.ResponseWriter, r *http.Request) {
@@ -55,9 +97,28 @@ func (cfg *ApiConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// MiddlewareNoFraming stops other sites embedding the pages next serves,
+// so a page such as the OAuth consent screen can't be overlaid and
+// clickjacked.
+func MiddlewareNoFraming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+		next.ServeHTTP(w, r)
+	})
+}
 
+// withTx runs fn against a transaction-scoped copy of the queries,
+// committing only if fn succeeds.
+func (cfg *ApiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-
-
-
-
+	if err := fn(cfg.Database.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
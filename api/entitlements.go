@@ -0,0 +1,50 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"context"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/entitlement"
+)
+
+// entitlementsFor looks up what the user's current tier allows.
+func (cfg *ApiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlement.Entitlements, error) {
+	isChirpyRed, err := cfg.isChirpyRed(ctx, userID)
+	if err != nil {
+		return entitlement.Entitlements{}, err
+	}
+	return cfg.Entitlements.For(entitlement.TierFor(isChirpyRed)), nil
+}
+
+// GetEntitlements tells clients the caller's limits, so they can be shown
+// before a chirp is rejected for breaking one.
+func (cfg *ApiConfig) GetEntitlements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	entitlements, err := cfg.entitlementsFor(r.Context(), p.UserID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(entitlements); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
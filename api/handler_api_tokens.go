@@ -0,0 +1,334 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"errors"
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf8"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+const (
+	maxAPITokenNameLength = 64
+	maxAPITokenDays       = 365
+)
+
+// principal is whoever a request is authenticated as: a user signed in with
+// an access token, one of their personal API tokens, or an OAuth client
+// acting on their behalf.
+type principal struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	TokenID   uuid.NullUUID
+	ClientID  uuid.NullUUID
+	Scopes    []string
+}
+
+// delegated reports whether the principal is limited to Scopes rather than
+// being the user themselves.
+func (p principal) delegated() bool {
+	return p.TokenID.Valid || p.ClientID.Valid
+}
+
+// can reports whether the principal may act with scope. Access tokens from
+// a login can do everything an API token could be granted.
+func (p principal) can(scope string) bool {
+	if !p.delegated() {
+		return true
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+var (
+	errInvalidAPIToken   = errors.New("invalid or revoked API token")
+	errInvalidOAuthGrant = errors.New("access was revoked")
+	// errTokenLookup marks failures to check a token, as opposed to the
+	// token being invalid.
+	errTokenLookup = errors.New("token lookup failed")
+)
+
+// revokeDelegatedAccess revokes every API token and OAuth grant the user
+// has handed out, along with authorization codes not yet exchanged. A new
+// password calls for it, since whoever knew the old one could have made
+// them.
+func revokeDelegatedAccess(ctx context.Context, q *database.Queries, userID uuid.UUID) error {
+	if _, err := q.RevokeUserAPITokens(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := q.RevokeUserOAuthGrants(ctx, userID); err != nil {
+		return err
+	}
+	return q.DeleteUnusedOAuthCodes(ctx, userID)
+}
+
+// principalFromToken resolves a bearer token, which is either a JWT or an
+// API token.
+func (cfg *ApiConfig) principalFromToken(r *http.Request, tokenString string) (principal, error) {
+	if !auth.IsAPIToken(tokenString) {
+		claims, err := cfg.Keys.ParseJWTClaims(tokenString)
+		if err != nil {
+			return principal{}, err
+		}
+		if claims.ClientID == uuid.Nil {
+			return principal{UserID: claims.UserID, SessionID: claims.SessionID}, nil
+		}
+		// Client tokens stop working as soon as the user or the client's
+		// owner revokes access, not when they expire.
+		grant, err := cfg.Database.GetActiveOAuthGrant(r.Context(), claims.SessionID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && (grant.ClientID != claims.ClientID || grant.UserID != claims.UserID)) {
+			return principal{}, errInvalidOAuthGrant
+		}
+		if err != nil {
+			return principal{}, errors.Join(errTokenLookup, err)
+		}
+		return principal{
+			UserID:    grant.UserID,
+			SessionID: grant.ID,
+			ClientID:  uuid.NullUUID{UUID: grant.ClientID, Valid: true},
+			Scopes:    grant.Scopes,
+		}, nil
+	}
+
+	token, err := cfg.Database.GetActiveAPIToken(r.Context(), auth.HashAPIToken(tokenString))
+	if errors.Is(err, sql.ErrNoRows) {
+		return principal{}, errInvalidAPIToken
+	}
+	if err != nil {
+		return principal{}, errors.Join(errTokenLookup, err)
+	}
+	// last_used_at is informational, so a failure to record it shouldn't
+	// fail the request.
+	cfg.Database.TouchAPIToken(r.Context(), token.ID)
+	return principal{
+		UserID:  token.UserID,
+		TokenID: uuid.NullUUID{UUID: token.ID, Valid: true},
+		Scopes:  token.Scopes,
+	}, nil
+}
+
+// authenticate authenticates the caller and checks they may act with scope,
+// writing the error response itself when they can't.
+func (cfg *ApiConfig) authenticate(w http.ResponseWriter, r *http.Request, scope string) (principal, bool) {
+	tokenString, ok := requestAccessToken(w, r)
+	if !ok {
+		return principal{}, false
+	}
+
+	p, err := cfg.principalFromToken(r, tokenString)
+	if errors.Is(err, errTokenLookup) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return principal{}, false
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid token: %v"}`, err)))
+		return principal{}, false
+	}
+
+	if !p.can(scope) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Token is missing the %s scope"}`, scope)))
+		return principal{}, false
+	}
+	return p, true
+}
+
+type apiTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	// Token is only ever returned when the token is created.
+	Token string `json:"token,omitempty"`
+}
+
+func newAPITokenResponse(t database.ApiToken) apiTokenResponse {
+	resp := apiTokenResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Prefix:    t.TokenPrefix,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+	}
+	if t.LastUsedAt.Valid {
+		resp.LastUsedAt = &t.LastUsedAt.Time
+	}
+	if t.ExpiresAt.Valid {
+		resp.ExpiresAt = &t.ExpiresAt.Time
+	}
+	return resp
+}
+
+// CreateAPIToken issues a personal API token with the requested scopes. The
+// token itself is only shown in this response.
+func (cfg *ApiConfig) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var t struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&t)
+	name := strings.TrimSpace(t.Name)
+	if err != nil || len(name) == 0 || utf8.RuneCountInString(name) > maxAPITokenNameLength {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "name is required and must be at most %d characters"}`, maxAPITokenNameLength)))
+		return
+	}
+	if t.ExpiresInDays < 0 || t.ExpiresInDays > maxAPITokenDays {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "expires_in_days must be between 0 (never) and %d"}`, maxAPITokenDays)))
+		return
+	}
+
+	scopes, err := auth.NormalizeScopes(t.Scopes)
+	if err != nil || len(scopes) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "scopes must be one or more of %s"}`, strings.Join(auth.Scopes, ", "))))
+		return
+	}
+
+	tokenString, err := auth.MakeAPIToken()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Token"` + err.Error()))
+		return
+	}
+
+	var expiresAt sql.NullTime
+	if t.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, t.ExpiresInDays), Valid: true}
+	}
+	token, err := cfg.Database.CreateAPIToken(r.Context(), database.CreateAPITokenParams{
+		ID:          uuid.New(),
+		UserID:      claims.UserID,
+		Name:        name,
+		TokenHash:   auth.HashAPIToken(tokenString),
+		TokenPrefix: tokenString[:len(auth.APITokenPrefix)+8],
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := newAPITokenResponse(token)
+	resp.Token = tokenString
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) GetAPITokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := cfg.Database.ListAPITokens(r.Context(), claims.UserID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := make([]apiTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		resp = append(resp, newAPITokenResponse(token))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	tokenId, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid token ID"}`))
+		return
+	}
+
+	revoked, err := cfg.Database.RevokeAPIToken(r.Context(), database.RevokeAPITokenParams{
+		ID:     tokenId,
+		UserID: claims.UserID,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if revoked == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "API token not found"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,225 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"context"
+	"time"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/pagination"
+)
+
+type relationUser struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type relationListResponse struct {
+	Users      []relationUser `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// blockedBetween reports whether either user has blocked the other.
+// Anonymous viewers are never blocked. Callers answer with a 404 so the
+// block itself is not revealed.
+func (cfg *ApiConfig) blockedBetween(ctx context.Context, viewer uuid.NullUUID, other uuid.UUID) (bool, error) {
+	if !viewer.Valid || viewer.UUID == other {
+		return false, nil
+	}
+	return cfg.Database.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+		UserID:  viewer.UUID,
+		OtherID: other,
+	})
+}
+
+// getVisibleChirp loads a live chirp the viewer may see. Missing, tombstoned
+// and blocked chirps all come back as sql.ErrNoRows.
+func (cfg *ApiConfig) getVisibleChirp(ctx context.Context, viewer uuid.NullUUID, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.Database.GetChirpById(ctx, id)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	blocked, err := cfg.blockedBetween(ctx, viewer, chirp.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if blocked {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (cfg *ApiConfig) BlockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleRelation(w, r, http.MethodPost, func(ctx context.Context, userId, otherId uuid.UUID) error {
+		// Blocking also ends any follow relationship in both directions.
+		return cfg.withTx(ctx, func(q *database.Queries) error {
+			err := q.BlockUser(ctx, database.BlockUserParams{BlockerID: userId, BlockedID: otherId})
+			if err != nil {
+				return err
+			}
+			return q.DeleteFollowsBetween(ctx, database.DeleteFollowsBetweenParams{UserID: userId, OtherID: otherId})
+		})
+	})
+}
+
+func (cfg *ApiConfig) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleRelation(w, r, http.MethodDelete, func(ctx context.Context, userId, otherId uuid.UUID) error {
+		_, err := cfg.Database.UnblockUser(ctx, database.UnblockUserParams{BlockerID: userId, BlockedID: otherId})
+		return err
+	})
+}
+
+func (cfg *ApiConfig) MuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleRelation(w, r, http.MethodPost, func(ctx context.Context, userId, otherId uuid.UUID) error {
+		return cfg.Database.MuteUser(ctx, database.MuteUserParams{MuterID: userId, MutedID: otherId})
+	})
+}
+
+func (cfg *ApiConfig) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleRelation(w, r, http.MethodDelete, func(ctx context.Context, userId, otherId uuid.UUID) error {
+		_, err := cfg.Database.UnmuteUser(ctx, database.UnmuteUserParams{MuterID: userId, MutedID: otherId})
+		return err
+	})
+}
+
+// handleRelation authenticates the caller and applies a block or mute change
+// against the user in the path. Repeating a change is a no-op.
+func (cfg *ApiConfig) handleRelation(w http.ResponseWriter, r *http.Request, method string, apply func(ctx context.Context, userId, otherId uuid.UUID) error) {
+	if r.Method != method {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeRelationshipsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	otherId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid user ID"}`))
+		return
+	}
+	if otherId == userId {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "You cannot block or mute yourself"}`))
+		return
+	}
+
+	_, err = cfg.Database.GetUserByID(r.Context(), otherId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "User not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	if err := apply(r.Context(), userId, otherId); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	cfg.handleRelationList(w, r, func(ctx context.Context, params database.ListBlocksParams) ([]relationUser, error) {
+		rows, err := cfg.Database.ListBlocks(ctx, params)
+		users := make([]relationUser, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, relationUser{ID: row.BlockedID, CreatedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
+
+func (cfg *ApiConfig) GetMutes(w http.ResponseWriter, r *http.Request) {
+	cfg.handleRelationList(w, r, func(ctx context.Context, params database.ListBlocksParams) ([]relationUser, error) {
+		rows, err := cfg.Database.ListMutes(ctx, database.ListMutesParams(params))
+		users := make([]relationUser, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, relationUser{ID: row.MutedID, CreatedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
+
+// handleRelationList pages through the caller's own blocks or mutes; nobody
+// else can see them.
+func (cfg *ApiConfig) handleRelationList(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, params database.ListBlocksParams) ([]relationUser, error)) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeRelationshipsRead)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	users, err := list(r.Context(), database.ListBlocksParams{
+		UserID:          userId,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	nextCursor := ""
+	if len(users) > page.Limit {
+		users = users[:page.Limit]
+		last := users[len(users)-1]
+		nextCursor = pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(relationListResponse{
+		Users:      users,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package apiConfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+func TestBlockHidesUsersBothWays(t *testing.T) {
+	cfg := newTestConfig(t)
+	blocker := createTestUser(t, cfg, "blocker")
+	blocked := createTestUser(t, cfg, "blocked")
+	chirp := createTestChirp(t, cfg, blocker.ID, "hello", uuid.NullUUID{})
+	err := cfg.Database.BlockUser(context.Background(), database.BlockUserParams{BlockerID: blocker.ID, BlockedID: blocked.ID})
+	if err != nil {
+		t.Fatalf("BlockUser() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		viewer  uuid.UUID
+		pattern string
+		handler http.HandlerFunc
+		path    string
+	}{
+		{name: "Profile of blocker", viewer: blocked.ID, pattern: "GET /api/users/{handleOrID}", handler: cfg.GetUserProfile, path: fmt.Sprintf("/api/users/%s", blocker.ID)},
+		{name: "Profile of blocked", viewer: blocker.ID, pattern: "GET /api/users/{handleOrID}", handler: cfg.GetUserProfile, path: fmt.Sprintf("/api/users/%s", blocked.ID)},
+		{name: "Followers of blocker", viewer: blocked.ID, pattern: "GET /api/users/{userID}/followers", handler: cfg.GetFollowers, path: fmt.Sprintf("/api/users/%s/followers", blocker.ID)},
+		{name: "Following of blocked", viewer: blocker.ID, pattern: "GET /api/users/{userID}/following", handler: cfg.GetFollowing, path: fmt.Sprintf("/api/users/%s/following", blocked.ID)},
+		{name: "Likes of blocker's chirp", viewer: blocked.ID, pattern: "GET /api/chirps/{chirpID}/likes", handler: cfg.GetChirpLikes, path: chirpPath(chirp.ID) + "/likes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, cfg, tt.viewer, tt.pattern, tt.handler, http.MethodGet, tt.path, "")
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want 404; body %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestThreadReplyCountsSkipBlockedUsers(t *testing.T) {
+	cfg := newTestConfig(t)
+	viewer := createTestUser(t, cfg, "viewer")
+	author := createTestUser(t, cfg, "author")
+	blocked := createTestUser(t, cfg, "blocked")
+	root := createTestChirp(t, cfg, author.ID, "root", uuid.NullUUID{})
+	reply := createTestChirp(t, cfg, author.ID, "reply", uuid.NullUUID{UUID: root.ID, Valid: true})
+	createTestChirp(t, cfg, blocked.ID, "hidden", uuid.NullUUID{UUID: root.ID, Valid: true})
+	createTestChirp(t, cfg, blocked.ID, "hidden too", uuid.NullUUID{UUID: reply.ID, Valid: true})
+	err := cfg.Database.BlockUser(context.Background(), database.BlockUserParams{BlockerID: viewer.ID, BlockedID: blocked.ID})
+	if err != nil {
+		t.Fatalf("BlockUser() error = %v", err)
+	}
+
+	rec := serve(t, cfg, viewer.ID, "GET /api/chirps/{chirpID}/thread", cfg.GetChirpThread, http.MethodGet, chirpPath(root.ID)+"/thread", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetChirpThread() status = %d, body %s", rec.Code, rec.Body)
+	}
+	var thread struct {
+		Chirp   chirpResponse   `json:"chirp"`
+		Replies []chirpResponse `json:"replies"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&thread); err != nil {
+		t.Fatalf("decoding thread: %v", err)
+	}
+	if thread.Chirp.ReplyCount == nil || *thread.Chirp.ReplyCount != 1 {
+		t.Errorf("chirp reply_count = %v, want 1", thread.Chirp.ReplyCount)
+	}
+	if len(thread.Replies) != 1 || thread.Replies[0].ReplyCount == nil || *thread.Replies[0].ReplyCount != 0 {
+		t.Errorf("replies = %+v, want only %s with reply_count 0", thread.Replies, reply.ID)
+	}
+}
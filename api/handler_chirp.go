@@ -1,6 +1,7 @@
 package apiConfig
 
 import (
+	"context"
 	"net/http"
 	"encoding/json"
 	"fmt"
@@ -9,9 +10,110 @@ import (
 	"github.com/google/uuid"
 	"database/sql"
 	"errors"
+	"time"
 	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/pagination"
+	"github.com/samuelhamann/chirpy/internal/entitlement"
 )
 
+type chirpResponse struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Body         string        `json:"body"`
+	UserID       uuid.UUID     `json:"user_id"`
+	InReplyToID  uuid.NullUUID `json:"in_reply_to_id"`
+	Deleted      bool          `json:"deleted,omitempty"`
+	ReplyCount   *int64        `json:"reply_count,omitempty"`
+	LikeCount    int32         `json:"like_count"`
+	RechirpCount int32         `json:"rechirp_count"`
+	LikedByMe    bool          `json:"liked_by_me"`
+	Edited       bool          `json:"edited"`
+	EditedAt     *time.Time    `json:"edited_at,omitempty"`
+	Entities     chirpEntities `json:"entities"`
+	Attachments  []attachmentResponse `json:"attachments"`
+}
+
+type chirpsResponse struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	resp := chirpResponse{
+		ID:           chirp.ID,
+		CreatedAt:    chirp.CreatedAt,
+		UpdatedAt:    chirp.UpdatedAt,
+		Body:         chirp.Body,
+		UserID:       chirp.UserID,
+		InReplyToID:  chirp.InReplyToID,
+		Deleted:      chirp.DeletedAt.Valid,
+		LikeCount:    chirp.LikeCount,
+		RechirpCount: chirp.RechirpCount,
+		Edited:       chirp.EditedAt.Valid,
+		Entities: chirpEntities{
+			Hashtags: []hashtagEntity{},
+			Mentions: []mentionEntity{},
+		},
+		Attachments: []attachmentResponse{},
+	}
+	if chirp.EditedAt.Valid {
+		resp.EditedAt = &chirp.EditedAt.Time
+	}
+	return resp
+}
+
+// decorateChirps fills in the parts of a chirp response that don't live on
+// the chirps row: the viewer's likes, the parsed entities and attachments.
+func (cfg *ApiConfig) decorateChirps(ctx context.Context, viewer uuid.NullUUID, chirps []chirpResponse) error {
+	if err := cfg.markLikedByMe(ctx, viewer, chirps); err != nil {
+		return err
+	}
+	if err := cfg.attachEntities(ctx, chirps); err != nil {
+		return err
+	}
+	return cfg.attachMedia(ctx, chirps)
+}
+
+// writeChirpPage trims the look-ahead row fetched by keyset queries on
+// (created_at, id) and writes the page with its next cursor.
+func (cfg *ApiConfig) writeChirpPage(w http.ResponseWriter, r *http.Request, viewer uuid.NullUUID, chirps []database.Chirp, page pagination.Page) {
+	nextCursor := ""
+	if len(chirps) > page.Limit {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		nextCursor = page.Next(last.CreatedAt, last.ID)
+	}
+
+	resp := newChirpsResponse(chirps)
+	err := cfg.decorateChirps(r.Context(), viewer, resp)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(chirpsResponse{
+		Chirps:     resp,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func newChirpsResponse(chirps []database.Chirp) []chirpResponse {
+	resp := make([]chirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		resp = append(resp, newChirpResponse(chirp))
+	}
+	return resp
+}
 
 func (cfg *ApiConfig) ValidateChirp(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -30,7 +132,19 @@ func (cfg *ApiConfig) ValidateChirp(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`"error": "Something went wrong"`))
 		return
 	}
-	if len(c.Body) == 0 || len(c.Body) > 140 {
+	// Anyone may ask, so the limits are the free tier's unless the caller
+	// is signed in.
+	entitlements := cfg.Entitlements.For(entitlement.TierFree)
+	if viewer := cfg.viewerID(r); viewer.Valid {
+		entitlements, err = cfg.entitlementsFor(r.Context(), viewer.UUID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+			return
+		}
+	}
+	if err := entitlements.CheckChirp(c.Body, 0); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(`"error": "Chirp is too long"`))
@@ -52,38 +166,127 @@ func (cfg *ApiConfig) CreateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString, err := auth.GetBearerToken(r.Header)
-    if err != nil {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusUnauthorized)
-        w.Write([]byte(`{"error": "Missing or invalid bearer token"}`))
-        return
-    }
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
 
-    userId, err := auth.ParseJWT(tokenString, cfg.JWTSecret)
-    if err != nil {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusUnauthorized)
-        w.Write([]byte(fmt.Sprintf(`{"error": "Invalid token: %v"}`, err)))
-        return
-    }
+	if cfg.RequireVerifiedEmail {
+		user, err := cfg.Database.GetUserByID(r.Context(), userId)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+			return
+		}
+		if !user.EmailVerifiedAt.Valid {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Verify your email address before posting"}`))
+			return
+		}
+	}
 
 	var c struct {
 		Body   string `json:"body"`
+		InReplyToID string `json:"in_reply_to_id"`
+		AttachmentIDs []string `json:"attachment_ids"`
+		// PublishAt schedules the chirp to be posted later instead of now.
+		PublishAt *time.Time `json:"publish_at"`
 	}
-	err = json.NewDecoder(r.Body).Decode(&c)
-	if err != nil || len(c.Body) == 0 || len(c.Body) > 140 {
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(`"error": "Something went wrong -- body"`))
 		return
 	}
-	fmt.Sprintf("Creating chirp for user ID: %s\n", userId)
 
-	chirp, err := cfg.Database.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   c.Body,
-		UserID: userId,
+	entitlements, err := cfg.entitlementsFor(r.Context(), userId)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if err := entitlements.CheckChirp(c.Body, len(c.AttachmentIDs)); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+
+	attachmentIds, err := parseAttachmentIDs(c.AttachmentIDs)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+
+	var inReplyToID uuid.NullUUID
+	if len(c.InReplyToID) > 0 {
+		parentId, err := uuid.Parse(c.InReplyToID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid in_reply_to_id"}`))
+			return
+		}
+		// Replying to someone on the other side of a block looks like
+		// replying to a chirp that doesn't exist.
+		_, err = cfg.getVisibleChirp(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, parentId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+			return
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "Parent chirp not found"}`))
+			return
+		}
+		inReplyToID = uuid.NullUUID{UUID: parentId, Valid: true}
+	}
+
+	if c.PublishAt != nil {
+		cfg.scheduleChirp(w, r, entitlements, userId, c.Body, inReplyToID, attachmentIds, c.PublishAt.UTC())
+		return
+	}
+
+	var chirp database.Chirp
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		if err := checkChirpRate(r.Context(), q, entitlements, userId); err != nil {
+			return err
+		}
+		var err error
+		chirp, err = postChirp(r.Context(), q, userId, c.Body, inReplyToID, attachmentIds)
+		return err
 	})
+	if errors.Is(err, entitlement.ErrRateLimited) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+	if errors.Is(err, errInvalidAttachments) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Unknown or already attached media"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := []chirpResponse{newChirpResponse(chirp)}
+	err = cfg.decorateChirps(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, resp)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -93,13 +296,51 @@ func (cfg *ApiConfig) CreateChirp(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(chirp)
+	err = json.NewEncoder(w).Encode(resp[0])
 	if err != nil {
 		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
 		return
 	}
 }
 
+// checkChirpRate holds the user's posting lock until the transaction ends
+// and checks they are under their hourly limit. Without the lock, requests
+// made at once would all count the same chirps and all get through.
+// Scheduled chirps count when they are scheduled, so the limit can't be
+// dodged by posting in advance.
+func checkChirpRate(ctx context.Context, q *database.Queries, entitlements entitlement.Entitlements, userId uuid.UUID) error {
+	if err := q.LockUserPosting(ctx, userId); err != nil {
+		return err
+	}
+	recent, err := q.CountRecentChirps(ctx, database.CountRecentChirpsParams{
+		UserID: userId,
+		Since:  time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		return err
+	}
+	return entitlements.CheckRate(int(recent))
+}
+
+// postChirp creates a chirp with its attachments and entities.
+func postChirp(ctx context.Context, q *database.Queries, userId uuid.UUID, body string, inReplyToID uuid.NullUUID, attachmentIds []uuid.UUID) (database.Chirp, error) {
+	chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:        body,
+		UserID:      userId,
+		InReplyToID: inReplyToID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if err := attachToChirp(ctx, q, chirp.ID, userId, attachmentIds); err != nil {
+		return database.Chirp{}, err
+	}
+	if err := saveChirpEntities(ctx, q, chirp); err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, nil
+}
+
 func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Content-Type", "application/json")
@@ -108,22 +349,75 @@ func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirps, err := cfg.Database.GetChirps(r.Context())
+	query := r.URL.Query()
+
+	var authorID uuid.NullUUID
+	if raw := query.Get("author_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid author_id"}`))
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	sortOrder := strings.ToLower(query.Get("sort"))
+	if sortOrder == "" {
+		sortOrder = "asc"
+	}
+	if sortOrder != "asc" && sortOrder != "desc" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "sort must be asc or desc"}`))
+		return
+	}
+
+	// The cursor records the sort and author it was issued for, since a
+	// keyset position means nothing in another listing.
+	scope := "sort=" + sortOrder
+	if authorID.Valid {
+		scope += ",author_id=" + authorID.UUID.String()
+	}
+	page, err := pagination.ParseScopedPage(query, scope)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(chirps)
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	// Fetch one extra row so we know whether another page exists.
+	viewer := cfg.viewerID(r)
+	var chirps []database.Chirp
+	if sortOrder == "desc" {
+		chirps, err = cfg.Database.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			ViewerID:        viewer,
+			PageLimit:       int32(page.Limit + 1),
+		})
+	} else {
+		chirps, err = cfg.Database.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			ViewerID:        viewer,
+			PageLimit:       int32(page.Limit + 1),
+		})
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`"error": "Something went wrong -- Encode"`))
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
+
+	cfg.writeChirpPage(w, r, viewer, chirps, page)
 }
 
 func (cfg *ApiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -143,7 +437,8 @@ func (cfg *ApiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.Database.GetChirpById(r.Context(), uuidId)
+	viewer := cfg.viewerID(r)
+	chirp, err := cfg.getVisibleChirp(r.Context(), viewer, uuidId)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			w.Header().Set("Content-Type", "application/json")
@@ -158,9 +453,18 @@ func (cfg *ApiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resp := []chirpResponse{newChirpResponse(chirp)}
+	err = cfg.decorateChirps(r.Context(), viewer, resp)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(chirp)
+	err = json.NewEncoder(w).Encode(resp[0])
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -177,30 +481,288 @@ func (cfg *ApiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString, err := auth.GetBearerToken(r.Header)
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/chirps/")
+	uuidId, err := uuid.Parse(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"error": "Something went wrong -- chirpID"`))
+		return
+	}
+
+	// A chirp with replies becomes a tombstone so the thread stays intact.
+	// The chirp is locked before its replies are counted: posting a reply
+	// has to wait for the lock, so no reply can slip in between the count
+	// and the delete.
+	found := true
+	var blobKeys []string
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		chirp, err := q.LockChirp(r.Context(), uuidId)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && chirp.DeletedAt.Valid) {
+			found = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if chirp.UserID != userId {
+			return sql.ErrNoRows
+		}
+
+		replyCount, err := q.CountReplies(r.Context(), uuid.NullUUID{UUID: uuidId, Valid: true})
+		if err != nil {
+			return err
+		}
+		blobKeys, err = q.DeleteChirpAttachments(r.Context(), uuid.NullUUID{UUID: uuidId, Valid: true})
+		if err != nil {
+			return err
+		}
+		if replyCount == 0 {
+			_, err = q.DeleteChirp(r.Context(), database.DeleteChirpParams{
+				ID:     uuidId,
+				UserID: userId,
+			})
+			return err
+		}
+		_, err = q.TombstoneChirp(r.Context(), database.TombstoneChirpParams{
+			ID:     uuidId,
+			UserID: userId,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.DeleteChirpRevisions(r.Context(), uuidId); err != nil {
+			return err
+		}
+		if err := q.DeleteChirpHashtags(r.Context(), uuidId); err != nil {
+			return err
+		}
+		return q.DeleteChirpMentions(r.Context(), uuidId)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`"error": "Chirp not found or not owned by user"`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if !found {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`"error": "Chirp not found"`))
+		return
+	}
+
+	cfg.deleteBlobs(r.Context(), blobKeys)
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func sanitize(input string) string {
+	badWords := []string{"kerfuffle", "sharbert", "fornax", "Kerfuffle", "Sharbert", "Fornax"} // Replace with your actual words
+	for _, word := range badWords {
+		input = strings.ReplaceAll(input, word, "****")
+	}
+	return input
+}
+func (cfg *ApiConfig) GetChirpThread(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	uuidId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"error": "Something went wrong -- chirpID"`))
+		return
+	}
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+
+	// Tombstoned chirps are still returned here so the conversation renders.
+	viewer := cfg.viewerID(r)
+	chirp, err := cfg.Database.GetChirpById(r.Context(), uuidId)
+	if err == nil {
+		var blocked bool
+		blocked, err = cfg.blockedBetween(r.Context(), viewer, chirp.UserID)
+		if err == nil && blocked {
+			err = sql.ErrNoRows
+		}
+	}
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`"error": "Chirp not found"`))
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`"error": "Something went wrong -- Token"` + err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
-	if len(tokenString) == 0 {
+
+	ancestorRows, err := cfg.Database.GetChirpAncestors(r.Context(), database.GetChirpAncestorsParams{
+		ID:       uuidId,
+		ViewerID: viewer,
+	})
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`"error": "Something went wrong -- No Token"`))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
 
-	userId, err := auth.ParseJWT(tokenString, cfg.JWTSecret)
+	// Reply counts leave out what the viewer can't see, so they match the
+	// replies listed and don't give away blocked users' activity.
+	replyCount, err := cfg.Database.CountVisibleReplies(r.Context(), database.CountVisibleRepliesParams{
+		ChirpID:  uuid.NullUUID{UUID: uuidId, Valid: true},
+		ViewerID: viewer,
+	})
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`"error": "Something went wrong -- Parse JWT"` + err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
 
-	id := strings.TrimPrefix(r.URL.Path, "/api/chirps/")
-	uuidId, err := uuid.Parse(id)
+	cursorCreatedAt, cursorID := page.CursorParams()
+	replyRows, err := cfg.Database.ListReplies(r.Context(), database.ListRepliesParams{
+		ChirpID:         uuid.NullUUID{UUID: uuidId, Valid: true},
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		ViewerID:        viewer,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	nextCursor := ""
+	if len(replyRows) > page.Limit {
+		replyRows = replyRows[:page.Limit]
+		last := replyRows[len(replyRows)-1]
+		nextCursor = pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	ancestors := make([]chirpResponse, 0, len(ancestorRows))
+	for _, row := range ancestorRows {
+		ancestor := database.Chirp{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			InReplyToID:  row.InReplyToID,
+			DeletedAt:    row.DeletedAt,
+			LikeCount:    row.LikeCount,
+			RechirpCount: row.RechirpCount,
+			EditedAt:     row.EditedAt,
+		}
+		// Ancestors across a block keep the thread's shape but render
+		// like deleted chirps.
+		if row.Blocked {
+			ancestor.Body = ""
+			ancestor.DeletedAt = sql.NullTime{Time: row.UpdatedAt, Valid: true}
+		}
+		ancestors = append(ancestors, newChirpResponse(ancestor))
+	}
+
+	replies := make([]chirpResponse, 0, len(replyRows))
+	for _, row := range replyRows {
+		reply := newChirpResponse(database.Chirp{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			InReplyToID:  row.InReplyToID,
+			DeletedAt:    row.DeletedAt,
+			LikeCount:    row.LikeCount,
+			RechirpCount: row.RechirpCount,
+			EditedAt:     row.EditedAt,
+		})
+		reply.ReplyCount = &row.ReplyCount
+		replies = append(replies, reply)
+	}
+
+	focus := newChirpResponse(chirp)
+	focus.ReplyCount = &replyCount
+
+	// Mark the whole thread in one query, then split it back apart.
+	all := append(append(append([]chirpResponse{}, ancestors...), focus), replies...)
+	err = cfg.decorateChirps(r.Context(), viewer, all)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	ancestors, focus, replies = all[:len(ancestors)], all[len(ancestors)], all[len(ancestors)+1:]
+
+	type threadResponse struct {
+		Ancestors  []chirpResponse `json:"ancestors"`
+		Chirp      chirpResponse   `json:"chirp"`
+		Replies    []chirpResponse `json:"replies"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(threadResponse{
+		Ancestors:  ancestors,
+		Chirp:      focus,
+		Replies:    replies,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) UpdateChirp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	uuidId, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -208,7 +770,41 @@ func (cfg *ApiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.Database.GetChirpById(r.Context(), uuidId)
+	var c struct {
+		Body string `json:"body"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"error": "Something went wrong -- body"`))
+		return
+	}
+
+	entitlements, err := cfg.entitlementsFor(r.Context(), userId)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if err := entitlements.CheckEdit(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+	if err := entitlements.CheckChirp(c.Body, 0); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+
+	chirp, err := cfg.Database.GetChirpById(r.Context(), uuidId)
+	if err == nil && chirp.DeletedAt.Valid {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			w.Header().Set("Content-Type", "application/json")
@@ -222,16 +818,45 @@ func (cfg *ApiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
+	if chirp.UserID != userId {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`"error": "Chirp not found or not owned by user"`))
+		return
+	}
 
-	_, err = cfg.Database.DeleteChirp(r.Context(), database.DeleteChirpParams{
-		ID:     uuidId,
-		UserID: userId,
+	var editWindow sql.NullFloat64
+	if entitlements.EditWindow > 0 {
+		editWindow = sql.NullFloat64{Float64: time.Duration(entitlements.EditWindow).Seconds(), Valid: true}
+	}
+
+	// EditChirp snapshots the previous body into chirp_revisions and applies
+	// the new one in a single statement, holding a row lock on the chirp.
+	var edited database.Chirp
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		edited, err = q.EditChirp(r.Context(), database.EditChirpParams{
+			ID:                uuidId,
+			UserID:            userId,
+			EditWindowSeconds: editWindow,
+			Body:              c.Body,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.DeleteChirpHashtags(r.Context(), uuidId); err != nil {
+			return err
+		}
+		if err := q.DeleteChirpMentions(r.Context(), uuidId); err != nil {
+			return err
+		}
+		return saveChirpEntities(r.Context(), q, edited)
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte(`"error": "Chirp not found or not owned by user"`))
+			w.Write([]byte(`{"error": "Chirp can no longer be edited"}`))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -240,14 +865,81 @@ func (cfg *ApiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-	return
+	resp := []chirpResponse{newChirpResponse(edited)}
+	err = cfg.decorateChirps(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, resp)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(resp[0])
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
 }
 
-func sanitize(input string) string {
-	badWords := []string{"kerfuffle", "sharbert", "fornax", "Kerfuffle", "Sharbert", "Fornax"} // Replace with your actual words
-	for _, word := range badWords {
-		input = strings.ReplaceAll(input, word, "****")
+func (cfg *ApiConfig) GetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
 	}
-	return input
-}
\ No newline at end of file
+
+	uuidId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"error": "Something went wrong -- chirpID"`))
+		return
+	}
+
+	chirp, err := cfg.getVisibleChirp(r.Context(), cfg.viewerID(r), uuidId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`"error": "Chirp not found"`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	revisions, err := cfg.Database.ListChirpRevisions(r.Context(), uuidId)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if revisions == nil {
+		revisions = []database.ChirpRevision{}
+	}
+
+	type revisionsResponse struct {
+		ChirpID   uuid.UUID                `json:"chirp_id"`
+		Current   string                   `json:"current"`
+		Revisions []database.ChirpRevision `json:"revisions"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(revisionsResponse{
+		ChirpID:   chirp.ID,
+		Current:   chirp.Body,
+		Revisions: revisions,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
@@ -0,0 +1,163 @@
+package apiConfig
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/entitlement"
+	"github.com/samuelhamann/chirpy/internal/subscription"
+)
+
+func createTestChirp(t *testing.T, cfg *ApiConfig, userID uuid.UUID, body string, inReplyTo uuid.NullUUID) database.Chirp {
+	t.Helper()
+	chirp, err := cfg.Database.CreateChirp(context.Background(), database.CreateChirpParams{
+		Body:        body,
+		UserID:      userID,
+		InReplyToID: inReplyTo,
+	})
+	if err != nil {
+		t.Fatalf("CreateChirp() error = %v", err)
+	}
+	return chirp
+}
+
+func TestDeleteChirpWithoutReplies(t *testing.T) {
+	cfg := newTestConfig(t)
+	author := createTestUser(t, cfg, "author")
+	chirp := createTestChirp(t, cfg, author.ID, "hello", uuid.NullUUID{})
+
+	rec := serve(t, cfg, author.ID, "DELETE /api/chirps/{chirpID}", cfg.DeleteChirp, http.MethodDelete, chirpPath(chirp.ID), "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DeleteChirp() status = %d, body %s", rec.Code, rec.Body)
+	}
+	if _, err := cfg.Database.GetChirpById(context.Background(), chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetChirpById() after delete error = %v, want no rows", err)
+	}
+
+	rec = serve(t, cfg, author.ID, "DELETE /api/chirps/{chirpID}", cfg.DeleteChirp, http.MethodDelete, chirpPath(chirp.ID), "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DeleteChirp() again status = %d, want 404", rec.Code)
+	}
+}
+
+func TestDeleteChirpWithReplies(t *testing.T) {
+	cfg := newTestConfig(t)
+	author := createTestUser(t, cfg, "author")
+	replier := createTestUser(t, cfg, "replier")
+	parent := createTestChirp(t, cfg, author.ID, "hello #golang", uuid.NullUUID{})
+	reply := createTestChirp(t, cfg, replier.ID, "hi back", uuid.NullUUID{UUID: parent.ID, Valid: true})
+
+	rec := serve(t, cfg, author.ID, "DELETE /api/chirps/{chirpID}", cfg.DeleteChirp, http.MethodDelete, chirpPath(parent.ID), "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DeleteChirp() status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	tombstone, err := cfg.Database.GetChirpById(context.Background(), parent.ID)
+	if err != nil {
+		t.Fatalf("GetChirpById() error = %v, want the tombstone", err)
+	}
+	if !tombstone.DeletedAt.Valid || len(tombstone.Body) != 0 {
+		t.Errorf("tombstone got = %+v, want deleted with no body", tombstone)
+	}
+	stillReply, err := cfg.Database.GetChirpById(context.Background(), reply.ID)
+	if err != nil || stillReply.InReplyToID != (uuid.NullUUID{UUID: parent.ID, Valid: true}) {
+		t.Errorf("reply after delete got = %+v, %v, want it still attached", stillReply, err)
+	}
+}
+
+func TestDeleteChirpNotOwner(t *testing.T) {
+	cfg := newTestConfig(t)
+	author := createTestUser(t, cfg, "author")
+	other := createTestUser(t, cfg, "other")
+	chirp := createTestChirp(t, cfg, author.ID, "hello", uuid.NullUUID{})
+
+	rec := serve(t, cfg, other.ID, "DELETE /api/chirps/{chirpID}", cfg.DeleteChirp, http.MethodDelete, chirpPath(chirp.ID), "")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("DeleteChirp() status = %d, want 403", rec.Code)
+	}
+	if _, err := cfg.Database.GetChirpById(context.Background(), chirp.ID); err != nil {
+		t.Errorf("GetChirpById() error = %v, want the chirp kept", err)
+	}
+}
+
+func makeChirpyRed(t *testing.T, cfg *ApiConfig, userID uuid.UUID) {
+	t.Helper()
+	_, err := cfg.Database.SaveSubscription(context.Background(), database.SaveSubscriptionParams{
+		UserID:    userID,
+		Plan:      subscription.DefaultPlan,
+		Status:    subscription.StatusActive,
+		StartedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("SaveSubscription() error = %v", err)
+	}
+}
+
+func TestCreateLongChirp(t *testing.T) {
+	cfg := newTestConfig(t)
+	red := createTestUser(t, cfg, "red")
+	makeChirpyRed(t, cfg, red.ID)
+	free := createTestUser(t, cfg, "free")
+
+	limit := cfg.Entitlements.For(entitlement.TierChirpyRed).MaxChirpLength
+	body := strings.Repeat("x", limit)
+	request := fmt.Sprintf(`{"body": %q}`, body)
+
+	rec := serve(t, cfg, red.ID, "POST /api/chirps", cfg.CreateChirp, http.MethodPost, "/api/chirps", request)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateChirp() for Chirpy Red status = %d, body %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		ID uuid.UUID `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decoding CreateChirp() response: %v", err)
+	}
+	stored, err := cfg.Database.GetChirpById(context.Background(), created.ID)
+	if err != nil || stored.Body != body {
+		t.Errorf("GetChirpById() body length = %d, %v, want %d", len(stored.Body), err, limit)
+	}
+
+	rec = serve(t, cfg, free.ID, "POST /api/chirps", cfg.CreateChirp, http.MethodPost, "/api/chirps", request)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("CreateChirp() for a free user status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCreateChirpRateLimitUnderConcurrency(t *testing.T) {
+	cfg := newTestConfig(t)
+	free := cfg.Entitlements.For(entitlement.TierFree)
+	free.ChirpsPerHour = 2
+	cfg.Entitlements[entitlement.TierFree] = free
+	user := createTestUser(t, cfg, "busy")
+
+	codes := make(chan int, 5)
+	for range cap(codes) {
+		go func() {
+			rec := serve(t, cfg, user.ID, "POST /api/chirps", cfg.CreateChirp, http.MethodPost, "/api/chirps", `{"body": "hello"}`)
+			codes <- rec.Code
+		}()
+	}
+	created := 0
+	for range cap(codes) {
+		switch code := <-codes; code {
+		case http.StatusCreated:
+			created++
+		case http.StatusTooManyRequests:
+		default:
+			t.Errorf("CreateChirp() status = %d, want 201 or 429", code)
+		}
+	}
+	if created != free.ChirpsPerHour {
+		t.Errorf("CreateChirp() created %d chirps, want %d", created, free.ChirpsPerHour)
+	}
+}
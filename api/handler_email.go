@@ -0,0 +1,359 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"context"
+	"errors"
+	"log"
+	"crypto/sha256"
+	"encoding/hex"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/mailer"
+	"github.com/samuelhamann/chirpy/internal/password"
+	"github.com/samuelhamann/chirpy/internal/throttle"
+	"strconv"
+)
+
+const (
+	emailTokenPasswordReset = "password_reset"
+	emailTokenVerifyEmail   = "verify_email"
+
+	passwordResetTTL = time.Hour
+	verifyEmailTTL   = 48 * time.Hour
+
+	// mailSendTimeout bounds a background send, since nobody is waiting
+	// on it to fail.
+	mailSendTimeout = 30 * time.Second
+)
+
+var errInvalidEmailToken = errors.New("invalid or expired token")
+
+func hashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueEmailToken creates a single-use token for the user's current email
+// address, invalidating any earlier token with the same purpose so only the
+// newest email works.
+func issueEmailToken(ctx context.Context, q *database.Queries, user database.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	err = q.InvalidateEmailTokens(ctx, database.InvalidateEmailTokensParams{
+		UserID:  user.ID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = q.CreateEmailToken(ctx, database.CreateEmailTokenParams{
+		TokenHash: hashEmailToken(token),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sendMail delivers a message in the background. Responses never wait on
+// the mail server, which also keeps their timing from revealing whether an
+// address belongs to an account. Failures are only logged.
+func (cfg *ApiConfig) sendMail(to, subject, body string) {
+	msg := mailer.Message{From: cfg.MailFrom, To: to, Subject: subject, Body: body}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		if err := cfg.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("mail: sending %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// sendVerificationEmail mails the user a link proving they own their
+// current address.
+func (cfg *ApiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := issueEmailToken(ctx, cfg.Database, user, emailTokenVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	cfg.sendMail(user.Email, "Verify your Chirpy email address", fmt.Sprintf(
+		"Confirm that this is your email address by opening\n%s/verify-email?token=%s\n\nThe link expires in 48 hours. If you didn't sign up for Chirpy, you can ignore this email.\n",
+		cfg.PublicURL, token,
+	))
+	return nil
+}
+
+// RequestPasswordReset mails a reset link if the address belongs to an
+// account. It answers the same way either way so it can't be used to find
+// out who has signed up.
+func (cfg *ApiConfig) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	var p struct {
+		Email string `json:"email"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil || len(p.Email) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "email is required"}`))
+		return
+	}
+
+	// Requests are throttled per address and per client whether or not an
+	// account exists, so the limit doesn't give that away either.
+	keys := []loginKey{
+		{key: throttle.ResetKey(p.Email), policy: throttle.ResetPolicy},
+		{key: throttle.ResetIPKey(clientIP(r)), policy: throttle.ResetIPPolicy},
+	}
+	lockedUntil, err := loginLockedUntil(r.Context(), cfg.Database, keys)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if !lockedUntil.IsZero() {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": "Too many password reset requests, try again later"}`))
+		return
+	}
+	if err := recordLoginFailure(r.Context(), cfg.Database, r, keys, uuid.NullUUID{}); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	user, err := cfg.Database.GetUserByEmail(r.Context(), p.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if err == nil {
+		token, err := issueEmailToken(r.Context(), cfg.Database, user, emailTokenPasswordReset, passwordResetTTL)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+			return
+		}
+		cfg.sendMail(user.Email, "Reset your Chirpy password", fmt.Sprintf(
+			"Someone asked to reset the password for your Chirpy account.\n\nTo choose a new password, open\n%s/reset-password?token=%s\n\nThe link expires in 1 hour. If you didn't ask for this, you can ignore this email.\n",
+			cfg.PublicURL, token,
+		))
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmPasswordReset sets a new password using a token from
+// RequestPasswordReset and signs the account out everywhere.
+func (cfg *ApiConfig) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	var p struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil || len(p.Token) == 0 || len(p.Password) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "token and password are required"}`))
+		return
+	}
+
+	hashedPassword, err := cfg.Passwords.Hash(p.Password)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Hash"` + err.Error()))
+		return
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		token, err := q.ConsumeEmailToken(r.Context(), database.ConsumeEmailTokenParams{
+			TokenHash: hashEmailToken(p.Token),
+			Purpose:   emailTokenPasswordReset,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidEmailToken
+		}
+		if err != nil {
+			return err
+		}
+
+		user, err := q.GetUserByID(r.Context(), token.UserID)
+		if err != nil {
+			return err
+		}
+		// The link was for an address the account no longer uses.
+		if user.Email != token.Email {
+			return errInvalidEmailToken
+		}
+		// Returning the rejection rolls back consuming the token, so the
+		// user can try again with a better password.
+		if err := cfg.PasswordPolicy.Check(p.Password, user.Email); err != nil {
+			return err
+		}
+
+		_, err = q.UpdateUser(r.Context(), database.UpdateUserParams{
+			ID:      user.ID,
+			Column2: "",
+			Column3: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = q.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{UserID: user.ID})
+		if err != nil {
+			return err
+		}
+		if err := revokeDelegatedAccess(r.Context(), q, user.ID); err != nil {
+			return err
+		}
+		// Following the link proved the user reads this inbox.
+		if _, err := q.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{ID: user.ID, Email: token.Email}); err != nil {
+			return err
+		}
+		return recordSecurityEvent(r.Context(), q, r, uuid.NullUUID{UUID: user.ID, Valid: true}, securityEventPasswordReset, "")
+	})
+	if errors.Is(err, errInvalidEmailToken) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid or expired token"}`))
+		return
+	}
+	if password.IsViolation(err) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail marks the user's address as verified using a token from
+// sendVerificationEmail.
+func (cfg *ApiConfig) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	var v struct {
+		Token string `json:"token"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&v)
+	if err != nil || len(v.Token) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "token is required"}`))
+		return
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		token, err := q.ConsumeEmailToken(r.Context(), database.ConsumeEmailTokenParams{
+			TokenHash: hashEmailToken(v.Token),
+			Purpose:   emailTokenVerifyEmail,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidEmailToken
+		}
+		if err != nil {
+			return err
+		}
+		verified, err := q.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+			ID:    token.UserID,
+			Email: token.Email,
+		})
+		if err != nil {
+			return err
+		}
+		if verified == 0 {
+			return errInvalidEmailToken
+		}
+		return nil
+	})
+	if errors.Is(err, errInvalidEmailToken) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid or expired token"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerificationEmail sends a fresh verification link to the signed-in
+// user.
+func (cfg *ApiConfig) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Email is already verified"}`))
+		return
+	}
+
+	if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,258 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/pagination"
+)
+
+type engagementUser struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type engagementListResponse struct {
+	Users      []engagementUser `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type engagementCountResponse struct {
+	ChirpID      uuid.UUID `json:"chirp_id"`
+	LikeCount    *int32    `json:"like_count,omitempty"`
+	RechirpCount *int32    `json:"rechirp_count,omitempty"`
+}
+
+// viewerID identifies the caller on endpoints that also serve anonymous
+// readers. A missing or invalid token simply means there is no viewer.
+func (cfg *ApiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	tokenString, _, err := auth.RequestToken(r, auth.AccessTokenCookie)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	viewer, err := cfg.principalFromToken(r, tokenString)
+	if err != nil || !viewer.can(auth.ScopeChirpsRead) {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: viewer.UserID, Valid: true}
+}
+
+// markLikedByMe fills in LikedByMe for the viewer with a single query.
+func (cfg *ApiConfig) markLikedByMe(ctx context.Context, viewer uuid.NullUUID, chirps []chirpResponse) error {
+	if !viewer.Valid || len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	liked, err := cfg.Database.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+	likedSet := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		likedSet[id] = true
+	}
+	for i := range chirps {
+		chirps[i].LikedByMe = likedSet[chirps[i].ID]
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleEngagement(w, r, func(ctx context.Context, userId, chirpId uuid.UUID) (engagementCountResponse, error) {
+		count, err := cfg.Database.LikeChirp(ctx, database.LikeChirpParams{UserID: userId, ChirpID: chirpId})
+		return engagementCountResponse{ChirpID: chirpId, LikeCount: &count}, err
+	})
+}
+
+func (cfg *ApiConfig) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleEngagement(w, r, func(ctx context.Context, userId, chirpId uuid.UUID) (engagementCountResponse, error) {
+		count, err := cfg.Database.UnlikeChirp(ctx, database.UnlikeChirpParams{UserID: userId, ChirpID: chirpId})
+		return engagementCountResponse{ChirpID: chirpId, LikeCount: &count}, err
+	})
+}
+
+func (cfg *ApiConfig) RechirpChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleEngagement(w, r, func(ctx context.Context, userId, chirpId uuid.UUID) (engagementCountResponse, error) {
+		count, err := cfg.Database.Rechirp(ctx, database.RechirpParams{UserID: userId, ChirpID: chirpId})
+		return engagementCountResponse{ChirpID: chirpId, RechirpCount: &count}, err
+	})
+}
+
+func (cfg *ApiConfig) UndoRechirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleEngagement(w, r, func(ctx context.Context, userId, chirpId uuid.UUID) (engagementCountResponse, error) {
+		count, err := cfg.Database.UndoRechirp(ctx, database.UndoRechirpParams{UserID: userId, ChirpID: chirpId})
+		return engagementCountResponse{ChirpID: chirpId, RechirpCount: &count}, err
+	})
+}
+
+// handleEngagement authenticates the caller, makes sure the chirp is live and
+// runs one of the like/rechirp mutations. The mutations update the counter in
+// the same statement as the engagement row, so concurrent requests can't drift.
+func (cfg *ApiConfig) handleEngagement(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, userId, chirpId uuid.UUID) (engagementCountResponse, error)) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"error": "Something went wrong -- chirpID"`))
+		return
+	}
+
+	_, err = cfg.getVisibleChirp(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, chirpId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`"error": "Chirp not found"`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp, err := apply(r.Context(), userId, chirpId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`"error": "Chirp not found"`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) GetChirpLikes(w http.ResponseWriter, r *http.Request) {
+	cfg.handleEngagementList(w, r, func(ctx context.Context, params database.ListChirpLikesParams) ([]engagementUser, error) {
+		rows, err := cfg.Database.ListChirpLikes(ctx, params)
+		users := make([]engagementUser, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, engagementUser{ID: row.UserID, CreatedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
+
+func (cfg *ApiConfig) GetChirpRechirps(w http.ResponseWriter, r *http.Request) {
+	cfg.handleEngagementList(w, r, func(ctx context.Context, params database.ListChirpLikesParams) ([]engagementUser, error) {
+		rows, err := cfg.Database.ListRechirps(ctx, database.ListRechirpsParams(params))
+		users := make([]engagementUser, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, engagementUser{ID: row.UserID, CreatedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
+
+func (cfg *ApiConfig) handleEngagementList(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, params database.ListChirpLikesParams) ([]engagementUser, error)) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"error": "Something went wrong -- chirpID"`))
+		return
+	}
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	// Neither a chirp nor users on either side of a block with the viewer
+	// are listed.
+	viewer := cfg.viewerID(r)
+	_, err = cfg.getVisibleChirp(r.Context(), viewer, chirpId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`"error": "Chirp not found"`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	users, err := list(r.Context(), database.ListChirpLikesParams{
+		ChirpID:         chirpId,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		ViewerID:        viewer,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	nextCursor := ""
+	if len(users) > page.Limit {
+		users = users[:page.Limit]
+		last := users[len(users)-1]
+		nextCursor = pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(engagementListResponse{
+		Users:      users,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
@@ -0,0 +1,307 @@
+package apiConfig
+
+import (
+	"net/http"
+	"fmt"
+	"context"
+	"strings"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/entities"
+	"github.com/samuelhamann/chirpy/internal/pagination"
+	"github.com/samuelhamann/chirpy/internal/profile"
+)
+
+// Entity offsets are rune indexes into the chirp body, end exclusive, and
+// cover the leading # or @.
+type hashtagEntity struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type mentionEntity struct {
+	Username string    `json:"username"`
+	UserID   uuid.UUID `json:"user_id"`
+	Start    int       `json:"start"`
+	End      int       `json:"end"`
+}
+
+type chirpEntities struct {
+	Hashtags []hashtagEntity `json:"hashtags"`
+	Mentions []mentionEntity `json:"mentions"`
+}
+
+// saveChirpEntities parses the chirp body and stores its hashtags and the
+// mentions that resolve to a real user.
+func saveChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	parsed := entities.Parse(chirp.Body)
+
+	if len(parsed.Hashtags) > 0 {
+		params := database.CreateChirpHashtagsParams{ChirpID: chirp.ID, CreatedAt: chirp.CreatedAt}
+		for _, tag := range parsed.Hashtags {
+			params.Tags = append(params.Tags, tag.Tag)
+			params.StartIndexes = append(params.StartIndexes, int32(tag.Start))
+			params.EndIndexes = append(params.EndIndexes, int32(tag.End))
+		}
+		if err := q.CreateChirpHashtags(ctx, params); err != nil {
+			return err
+		}
+	}
+
+	if len(parsed.Mentions) == 0 {
+		return nil
+	}
+	resolved, err := resolveMentions(ctx, q, parsed.Mentions)
+	if err != nil {
+		return err
+	}
+	if err := dropBlockedMentions(ctx, q, chirp.UserID, resolved); err != nil {
+		return err
+	}
+	params := database.CreateChirpMentionsParams{ChirpID: chirp.ID, CreatedAt: chirp.CreatedAt}
+	for _, mention := range parsed.Mentions {
+		userId, ok := resolved[mention.Username]
+		if !ok {
+			continue
+		}
+		params.UserIds = append(params.UserIds, userId)
+		params.StartIndexes = append(params.StartIndexes, int32(mention.Start))
+		params.EndIndexes = append(params.EndIndexes, int32(mention.End))
+	}
+	if len(params.UserIds) == 0 {
+		return nil
+	}
+	return q.CreateChirpMentions(ctx, params)
+}
+
+// resolveMentions maps mention usernames to the users they refer to.
+// Users are mentioned by handle, as in @gopher, or by ID, as in @<uuid>;
+// unknown names are dropped.
+func resolveMentions(ctx context.Context, q *database.Queries, mentions []entities.Mention) (map[string]uuid.UUID, error) {
+	byID := map[uuid.UUID][]string{}
+	byHandle := map[string][]string{}
+	var ids []uuid.UUID
+	var handles []string
+	for _, mention := range mentions {
+		id, err := uuid.Parse(mention.Username)
+		if err != nil {
+			handle := profile.NormalizeHandle(mention.Username)
+			if _, seen := byHandle[handle]; !seen {
+				handles = append(handles, handle)
+			}
+			byHandle[handle] = append(byHandle[handle], mention.Username)
+			continue
+		}
+		if _, seen := byID[id]; !seen {
+			ids = append(ids, id)
+		}
+		byID[id] = append(byID[id], mention.Username)
+	}
+
+	resolved := map[string]uuid.UUID{}
+	if len(ids) > 0 {
+		existing, err := q.ListExistingUserIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range existing {
+			for _, name := range byID[id] {
+				resolved[name] = id
+			}
+		}
+	}
+	if len(handles) > 0 {
+		users, err := q.ListUsersByHandles(ctx, handles)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			for _, name := range byHandle[profile.NormalizeHandle(user.Handle.String)] {
+				resolved[name] = user.ID
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// dropBlockedMentions removes mentions of users on the other side of a block
+// from the author, so a block can't be worked around by @mentioning.
+func dropBlockedMentions(ctx context.Context, q *database.Queries, authorId uuid.UUID, resolved map[string]uuid.UUID) error {
+	if len(resolved) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(resolved))
+	for _, id := range resolved {
+		ids = append(ids, id)
+	}
+	blocked, err := q.FilterBlockedUsers(ctx, database.FilterBlockedUsersParams{
+		Ids:    ids,
+		UserID: authorId,
+	})
+	if err != nil {
+		return err
+	}
+	blockedSet := make(map[uuid.UUID]bool, len(blocked))
+	for _, id := range blocked {
+		blockedSet[id] = true
+	}
+	for name, id := range resolved {
+		if blockedSet[id] {
+			delete(resolved, name)
+		}
+	}
+	return nil
+}
+
+// attachEntities loads the stored hashtags and mentions for a page of chirps.
+func (cfg *ApiConfig) attachEntities(ctx context.Context, chirps []chirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	index := make(map[uuid.UUID][]int, len(chirps))
+	for i, chirp := range chirps {
+		if _, seen := index[chirp.ID]; !seen {
+			ids = append(ids, chirp.ID)
+		}
+		index[chirp.ID] = append(index[chirp.ID], i)
+	}
+
+	hashtags, err := cfg.Database.ListHashtagsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, tag := range hashtags {
+		for _, i := range index[tag.ChirpID] {
+			chirps[i].Entities.Hashtags = append(chirps[i].Entities.Hashtags, hashtagEntity{
+				Tag:   tag.Tag,
+				Start: int(tag.StartIndex),
+				End:   int(tag.EndIndex),
+			})
+		}
+	}
+
+	mentions, err := cfg.Database.ListMentionsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, mention := range mentions {
+		for _, i := range index[mention.ChirpID] {
+			body := []rune(chirps[i].Body)
+			start, end := int(mention.StartIndex), int(mention.EndIndex)
+			if start < 0 || end > len(body) || start+1 > end {
+				continue
+			}
+			chirps[i].Entities.Mentions = append(chirps[i].Entities.Mentions, mentionEntity{
+				Username: string(body[start+1 : end]),
+				UserID:   mention.UserID,
+				Start:    start,
+				End:      end,
+			})
+		}
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) GetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	// Accept the tag with or without its leading #, in any case.
+	raw := "#" + strings.TrimPrefix(r.PathValue("tag"), "#")
+	parsed := entities.Parse(raw)
+	if len(parsed.Hashtags) != 1 || parsed.Hashtags[0].End != len([]rune(raw)) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid hashtag"}`))
+		return
+	}
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	viewer := cfg.viewerID(r)
+	chirps, err := cfg.Database.ListHashtagChirps(r.Context(), database.ListHashtagChirpsParams{
+		Tag:             parsed.Hashtags[0].Tag,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		ViewerID:        viewer,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	cfg.writeChirpPage(w, r, viewer, chirps, page)
+}
+
+func (cfg *ApiConfig) GetUserMentions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid user ID"}`))
+		return
+	}
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	viewer := cfg.viewerID(r)
+	blocked, err := cfg.blockedBetween(r.Context(), viewer, userId)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if blocked {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+
+	chirps, err := cfg.Database.ListMentionChirps(r.Context(), database.ListMentionChirpsParams{
+		UserID:          userId,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		ViewerID:        viewer,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	cfg.writeChirpPage(w, r, viewer, chirps, page)
+}
@@ -0,0 +1,307 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/pagination"
+)
+
+type followUser struct {
+	ID         uuid.UUID `json:"id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followListResponse struct {
+	Users      []followUser `json:"users"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+func (cfg *ApiConfig) FollowUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeRelationshipsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	followeeId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid user ID"}`))
+		return
+	}
+	if followeeId == userId {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "You cannot follow yourself"}`))
+		return
+	}
+
+	_, err = cfg.Database.GetUserByID(r.Context(), followeeId)
+	if err == nil {
+		var blocked bool
+		blocked, err = cfg.blockedBetween(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, followeeId)
+		if err == nil && blocked {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "User not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	err = cfg.Database.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userId,
+		FolloweeID: followeeId,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeRelationshipsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	followeeId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid user ID"}`))
+		return
+	}
+
+	// Unfollowing someone you don't follow is a no-op, not an error.
+	_, err = cfg.Database.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userId,
+		FolloweeID: followeeId,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid user ID"}`))
+		return
+	}
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	viewer := cfg.viewerID(r)
+	blocked, err := cfg.blockedBetween(r.Context(), viewer, userId)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if blocked {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+
+	rows, err := cfg.Database.ListFollowers(r.Context(), database.ListFollowersParams{
+		UserID:          userId,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		ViewerID:        viewer,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	users := make([]followUser, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, followUser{ID: row.FollowerID, FollowedAt: row.CreatedAt})
+	}
+	writeFollowList(w, r, users, page.Limit)
+}
+
+func (cfg *ApiConfig) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid user ID"}`))
+		return
+	}
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	viewer := cfg.viewerID(r)
+	blocked, err := cfg.blockedBetween(r.Context(), viewer, userId)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if blocked {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+
+	rows, err := cfg.Database.ListFollowing(r.Context(), database.ListFollowingParams{
+		UserID:          userId,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		ViewerID:        viewer,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	users := make([]followUser, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, followUser{ID: row.FolloweeID, FollowedAt: row.CreatedAt})
+	}
+	writeFollowList(w, r, users, page.Limit)
+}
+
+// writeFollowList trims the extra look-ahead row and writes the page.
+func writeFollowList(w http.ResponseWriter, r *http.Request, users []followUser, limit int) {
+	nextCursor := ""
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[len(users)-1]
+		nextCursor = pagination.Cursor{CreatedAt: last.FollowedAt, ID: last.ID}.Encode()
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(followListResponse{
+		Users:      users,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	page, err := pagination.ParsePage(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+	cursorCreatedAt, cursorID := page.CursorParams()
+
+	chirps, err := cfg.Database.ListTimelineChirps(r.Context(), database.ListTimelineChirpsParams{
+		UserID:          userId,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       int32(page.Limit + 1),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	cfg.writeChirpPage(w, r, uuid.NullUUID{UUID: userId, Valid: true}, chirps, page)
+}
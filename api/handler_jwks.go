@@ -0,0 +1,26 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+)
+
+// GetJWKS publishes the public keys access tokens are verified with, so
+// other services can check Chirpy tokens without holding a secret. Caches
+// are kept short so a newly added key is picked up well before it signs.
+func (cfg *ApiConfig) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(cfg.Keys.JWKS()); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
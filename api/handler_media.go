@@ -0,0 +1,311 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"context"
+	"bytes"
+	"io"
+	"log"
+	"errors"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/blobstore"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/media"
+)
+
+const (
+	// mediaURLTTL is how long a signed download URL stays valid.
+	mediaURLTTL = time.Hour
+)
+
+var errInvalidAttachments = errors.New("unknown or already attached media")
+
+type attachmentResponse struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// newAttachmentResponse signs a download URL for the attachment. Expiry is
+// rounded so repeated reads hand out the same URL and caches stay warm.
+func (cfg *ApiConfig) newAttachmentResponse(attachment database.Attachment) attachmentResponse {
+	expires := time.Now().UTC().Truncate(mediaURLTTL / 4).Add(mediaURLTTL)
+	id := attachment.ID.String()
+	return attachmentResponse{
+		ID:           attachment.ID,
+		ContentType:  attachment.ContentType,
+		SizeBytes:    attachment.SizeBytes,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+		URL:          media.SignURL(cfg.MediaSigningKey, "/api/media/"+id, id, expires),
+		URLExpiresAt: expires,
+	}
+}
+
+// attachMedia loads the attachments for a page of chirps in one query.
+func (cfg *ApiConfig) attachMedia(ctx context.Context, chirps []chirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	index := make(map[uuid.UUID][]int, len(chirps))
+	for i, chirp := range chirps {
+		if _, seen := index[chirp.ID]; !seen {
+			ids = append(ids, chirp.ID)
+		}
+		index[chirp.ID] = append(index[chirp.ID], i)
+	}
+
+	attachments, err := cfg.Database.ListAttachmentsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		for _, i := range index[attachment.ChirpID.UUID] {
+			chirps[i].Attachments = append(chirps[i].Attachments, cfg.newAttachmentResponse(attachment))
+		}
+	}
+	return nil
+}
+
+// parseAttachmentIDs validates the attachment_ids of a new chirp.
+func parseAttachmentIDs(raw []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(raw))
+	seen := make(map[uuid.UUID]bool, len(raw))
+	for _, s := range raw {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attachment ID %q", s)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("attachment %s is listed twice", id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// attachToChirp claims the caller's unattached uploads for a chirp.
+func attachToChirp(ctx context.Context, q *database.Queries, chirpId, userId uuid.UUID, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	attached, err := q.AttachToChirp(ctx, database.AttachToChirpParams{
+		ChirpID: chirpId,
+		Ids:     ids,
+		UserID:  userId,
+	})
+	if err != nil {
+		return err
+	}
+	if len(attached) != len(ids) {
+		return errInvalidAttachments
+	}
+	return nil
+}
+
+// deleteBlobs removes stored media once the rows pointing at it are gone.
+// Failures only leak storage, so they are logged rather than returned.
+func (cfg *ApiConfig) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := cfg.Media.Delete(ctx, key); err != nil {
+			log.Printf("media: deleting %s: %v", key, err)
+		}
+	}
+}
+
+// RunMediaSweep deletes uploads that were never attached to anything once
+// they are older than ttl, checking immediately and then every interval
+// until ctx is cancelled. Avatars and media waiting on a scheduled chirp
+// are kept.
+func (cfg *ApiConfig) RunMediaSweep(ctx context.Context, interval, ttl time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		keys, err := cfg.Database.DeleteOrphanedAttachments(ctx, time.Now().UTC().Add(-ttl))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("media: sweeping unattached uploads: %v", err)
+		}
+		cfg.deleteBlobs(ctx, keys)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *ApiConfig) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+	userId := p.UserID
+
+	// Leave some room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MediaMaxBytes+64<<10)
+	if err := r.ParseMultipartForm(cfg.MediaMaxBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(fmt.Sprintf(`{"error": "Files must be at most %d bytes"}`, cfg.MediaMaxBytes)))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Expected a multipart/form-data body"}`))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Missing file field"}`))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, cfg.MediaMaxBytes+1))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Could not read file"}`))
+		return
+	}
+	if int64(len(data)) > cfg.MediaMaxBytes {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Files must be at most %d bytes"}`, cfg.MediaMaxBytes)))
+		return
+	}
+
+	info, err := media.Inspect(data)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupportedType) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			w.Write([]byte(`{"error": "Only PNG, JPEG and GIF images are supported"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "File is not a valid image"}`))
+		return
+	}
+
+	attachmentId := uuid.New()
+	key := "media/" + attachmentId.String() + info.Extension
+	err = cfg.Media.Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), info.ContentType)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Storage"` + err.Error()))
+		return
+	}
+
+	attachment, err := cfg.Database.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		ID:          attachmentId,
+		UserID:      userId,
+		StorageKey:  key,
+		ContentType: info.ContentType,
+		SizeBytes:   int64(len(data)),
+		Width:       int32(info.Width),
+		Height:      int32(info.Height),
+	})
+	if err != nil {
+		cfg.deleteBlobs(context.Background(), []string{key})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(cfg.newAttachmentResponse(attachment)); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// GetMedia serves attachment bytes to anyone holding a valid signed URL.
+func (cfg *ApiConfig) GetMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	attachmentId, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid media ID"}`))
+		return
+	}
+
+	err = media.VerifyURL(cfg.MediaSigningKey, attachmentId.String(), r.URL.Query(), time.Now())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+
+	attachment, err := cfg.Database.GetAttachment(r.Context(), attachmentId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "Media not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	body, err := cfg.Media.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "Media not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Storage"` + err.Error()))
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", fmt.Sprint(attachment.SizeBytes))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, body)
+}
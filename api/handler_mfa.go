@@ -0,0 +1,582 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"context"
+	"errors"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/mfa"
+	"github.com/samuelhamann/chirpy/internal/throttle"
+)
+
+const (
+	mfaIssuer = "Chirpy"
+	// mfaChallengeTTL is how long a user has to enter their code after the
+	// password step of a login.
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts is how many wrong codes a challenge survives before
+	// the login has to start over with the password.
+	maxMFAAttempts = 5
+)
+
+var errInvalidMFAChallenge = errors.New("invalid MFA challenge")
+
+// verifySecondFactor checks a TOTP code or an unused recovery code for a
+// user with MFA enabled, and consumes it so it can't be used again.
+func verifySecondFactor(ctx context.Context, q *database.Queries, user database.User, code string) (bool, error) {
+	if mfa.LooksLikeRecoveryCode(code) {
+		stored, err := q.ListUnusedRecoveryCodes(ctx, user.ID)
+		if err != nil {
+			return false, err
+		}
+		normalized := mfa.NormalizeRecoveryCode(code)
+		for _, recoveryCode := range stored {
+			match, err := auth.CheckPasswordHash(normalized, recoveryCode.CodeHash)
+			if err != nil {
+				return false, err
+			}
+			if match {
+				used, err := q.UseRecoveryCode(ctx, recoveryCode.ID)
+				return used == 1, err
+			}
+		}
+		return false, nil
+	}
+
+	counter, ok := mfa.Validate(user.TotpSecret.String, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	advanced, err := q.AdvanceTOTPCounter(ctx, database.AdvanceTOTPCounterParams{
+		ID:              user.ID,
+		TotpLastCounter: counter,
+	})
+	return advanced == 1, err
+}
+
+// replaceRecoveryCodes swaps the user's recovery codes for a new set and
+// returns them in plain text, the only time they are ever available.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userId uuid.UUID) ([]string, error) {
+	codes, err := mfa.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hash, err := auth.HashPassword(mfa.NormalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if err := q.DeleteRecoveryCodes(ctx, userId); err != nil {
+		return nil, err
+	}
+	err = q.CreateRecoveryCodes(ctx, database.CreateRecoveryCodesParams{
+		UserID:     userId,
+		CodeHashes: hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// startMFAChallenge answers the password step of a login for a user with
+// MFA enabled. No tokens are issued until the challenge is completed at
+// /api/login/mfa.
+func (cfg *ApiConfig) startMFAChallenge(w http.ResponseWriter, r *http.Request, user database.User, expiresIn int64, useCookies bool) {
+	challenge, err := auth.MakeRefreshToken()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Token"` + err.Error()))
+		return
+	}
+	expiresAt := time.Now().UTC().Add(mfaChallengeTTL)
+	err = cfg.Database.CreateMFAChallenge(r.Context(), database.CreateMFAChallengeParams{
+		Token:            challenge,
+		UserID:           user.ID,
+		ExpiresInSeconds: expiresIn,
+		UseCookies:       useCookies,
+		ExpiresAt:        expiresAt,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	type challengeResponse struct {
+		MFARequired bool      `json:"mfa_required"`
+		MFAToken    string    `json:"mfa_token"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(challengeResponse{MFARequired: true, MFAToken: challenge, ExpiresAt: expiresAt}); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// LoginMFA completes a login started by LoginUser, exchanging the MFA
+// challenge token and a TOTP or recovery code for the usual tokens.
+func (cfg *ApiConfig) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	var c struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil || len(c.MFAToken) == 0 || len(c.Code) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "mfa_token and code are required"}`))
+		return
+	}
+
+	var user database.User
+	var expiresIn int64
+	var useCookies bool
+	var keys []loginKey
+	var lockedUntil time.Time
+	verified := false
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		// Locking the challenge serialises guesses against it.
+		challenge, err := q.LockMFAChallenge(r.Context(), c.MFAToken)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidMFAChallenge
+		}
+		if err != nil {
+			return err
+		}
+		if !challenge.ExpiresAt.After(time.Now().UTC()) {
+			return errInvalidMFAChallenge
+		}
+		user, err = q.GetUserByID(r.Context(), challenge.UserID)
+		if err != nil {
+			return err
+		}
+		if !user.TotpEnabledAt.Valid {
+			return errInvalidMFAChallenge
+		}
+		// Wrong codes count towards the same lockout as wrong passwords,
+		// or fresh challenges would allow unlimited guessing.
+		keys = loginKeys(r, user.Email)
+		if err := lockLoginKeys(r.Context(), q, keys); err != nil {
+			return err
+		}
+		lockedUntil, err = loginLockedUntil(r.Context(), q, keys)
+		if err != nil || !lockedUntil.IsZero() {
+			return err
+		}
+
+		verified, err = verifySecondFactor(r.Context(), q, user, c.Code)
+		if err != nil {
+			return err
+		}
+		if verified {
+			expiresIn, useCookies = challenge.ExpiresInSeconds, challenge.UseCookies
+			if err := clearLoginFailures(r.Context(), q, user.Email); err != nil {
+				return err
+			}
+			return q.DeleteMFAChallenge(r.Context(), c.MFAToken)
+		}
+
+		// A wrong code is committed, not rolled back, so that the
+		// attempt counts.
+		err = recordLoginFailure(r.Context(), q, r, keys, uuid.NullUUID{UUID: user.ID, Valid: true})
+		if err != nil {
+			return err
+		}
+		attempts, err := q.RecordMFAChallengeFailure(r.Context(), c.MFAToken)
+		if err != nil {
+			return err
+		}
+		if attempts < maxMFAAttempts {
+			return nil
+		}
+		if err := q.DeleteMFAChallenge(r.Context(), c.MFAToken); err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("%d wrong codes for one login", attempts)
+		return recordSecurityEvent(r.Context(), q, r, uuid.NullUUID{UUID: user.ID, Valid: true}, securityEventMFAFailed, detail)
+	})
+	if errors.Is(err, errInvalidMFAChallenge) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid or expired MFA token"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if !lockedUntil.IsZero() {
+		writeLoginLocked(w, lockedUntil)
+		return
+	}
+	if !verified {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid code"}`))
+		return
+	}
+
+	cfg.completeLogin(w, r, user, expiresIn, useCookies)
+}
+
+// currentUser loads the user the bearer token belongs to, writing the error
+// response itself when that fails.
+func (cfg *ApiConfig) currentUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	_, user, ok := cfg.currentSession(w, r)
+	return user, ok
+}
+
+// currentSession is currentUser for callers that also need the session's
+// claims.
+func (cfg *ApiConfig) currentSession(w http.ResponseWriter, r *http.Request) (auth.Claims, database.User, bool) {
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return auth.Claims{}, database.User{}, false
+	}
+	user, err := cfg.Database.GetUserByID(r.Context(), claims.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return auth.Claims{}, database.User{}, false
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return auth.Claims{}, database.User{}, false
+	}
+	return claims, user, true
+}
+
+// EnrollTOTP starts TOTP enrollment by generating a secret for the user's
+// authenticator app. MFA isn't enforced until ConfirmTOTP sees a code from
+// it, so starting again simply replaces the pending secret.
+func (cfg *ApiConfig) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Secret"` + err.Error()))
+		return
+	}
+	updated, err := cfg.Database.SetPendingTOTPSecret(r.Context(), database.SetPendingTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if updated == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Two-factor authentication is already enabled"}`))
+		return
+	}
+
+	account := user.Email
+	if user.Handle.Valid {
+		account = "@" + user.Handle.String
+	}
+
+	type enrollResponse struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(enrollResponse{Secret: secret, OTPAuthURI: mfa.URI(mfaIssuer, account, secret)}); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// ConfirmTOTP turns MFA on once the user proves their app has the secret,
+// and hands out the first set of recovery codes.
+func (cfg *ApiConfig) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var c struct {
+		Code string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil || len(c.Code) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "code is required"}`))
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Two-factor authentication is already enabled"}`))
+		return
+	}
+	if !user.TotpSecret.Valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Start enrollment first"}`))
+		return
+	}
+
+	counter, ok := mfa.Validate(user.TotpSecret.String, c.Code, time.Now())
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid code"}`))
+		return
+	}
+
+	var codes []string
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		enabled, err := q.EnableTOTP(r.Context(), database.EnableTOTPParams{
+			ID:              user.ID,
+			TotpLastCounter: counter,
+		})
+		if err != nil {
+			return err
+		}
+		if enabled == 0 {
+			return sql.ErrNoRows
+		}
+		codes, err = replaceRecoveryCodes(r.Context(), q, user.ID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Two-factor authentication is already enabled"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	writeRecoveryCodes(w, codes)
+}
+
+// DisableTOTP turns MFA off. It takes a current code, so a stolen access
+// token alone can't remove the second factor.
+func (cfg *ApiConfig) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	disabled := cfg.withSecondFactor(w, r, func(q *database.Queries, user database.User) error {
+		if err := q.DisableTOTP(r.Context(), user.ID); err != nil {
+			return err
+		}
+		return q.DeleteRecoveryCodes(r.Context(), user.ID)
+	})
+	if disabled {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes, used or
+// not, with a new set.
+func (cfg *ApiConfig) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	var codes []string
+	replaced := cfg.withSecondFactor(w, r, func(q *database.Queries, user database.User) error {
+		var err error
+		codes, err = replaceRecoveryCodes(r.Context(), q, user.ID)
+		return err
+	})
+	if replaced {
+		writeRecoveryCodes(w, codes)
+	}
+}
+
+// withSecondFactor authenticates the caller, checks the code in the request
+// body and runs fn in the same transaction that consumes the code. It writes
+// the error response when anything fails and reports whether fn committed.
+// Wrong codes are throttled like LoginMFA's: they count towards the login
+// lockout, and maxMFAAttempts of them in one session sign it out.
+func (cfg *ApiConfig) withSecondFactor(w http.ResponseWriter, r *http.Request, fn func(q *database.Queries, user database.User) error) bool {
+	claims, user, ok := cfg.currentSession(w, r)
+	if !ok {
+		return false
+	}
+
+	var c struct {
+		Code string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil || len(c.Code) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "code is required"}`))
+		return false
+	}
+	if !user.TotpEnabledAt.Valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Two-factor authentication is not enabled"}`))
+		return false
+	}
+
+	keys := loginKeys(r, user.Email)
+	sessionKey := throttle.SessionKey(claims.SessionID.String())
+	var lockedUntil time.Time
+	verified := false
+	signedOut := false
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		lockKeys := append(keys, loginKey{key: sessionKey})
+		if err := lockLoginKeys(r.Context(), q, lockKeys); err != nil {
+			return err
+		}
+		var err error
+		lockedUntil, err = loginLockedUntil(r.Context(), q, lockKeys)
+		if err != nil || !lockedUntil.IsZero() {
+			return err
+		}
+
+		verified, err = verifySecondFactor(r.Context(), q, user, c.Code)
+		if err != nil {
+			return err
+		}
+		if verified {
+			if _, err := q.ClearLoginThrottles(r.Context(), []string{sessionKey}); err != nil {
+				return err
+			}
+			return fn(q, user)
+		}
+
+		// A wrong code is committed, not rolled back, so that the
+		// attempt counts.
+		err = recordLoginFailure(r.Context(), q, r, keys, uuid.NullUUID{UUID: user.ID, Valid: true})
+		if err != nil {
+			return err
+		}
+		attempts, err := q.RecordLoginFailure(r.Context(), database.RecordLoginFailureParams{
+			Key:         sessionKey,
+			ResetBefore: time.Now().UTC().Add(-throttle.AccountPolicy.ResetAfter),
+		})
+		if err != nil {
+			return err
+		}
+		if attempts < maxMFAAttempts {
+			return nil
+		}
+		// The session is signed out, and its access token, which outlives
+		// it, is refused here until it has expired.
+		signedOut = true
+		err = q.LockLoginThrottle(r.Context(), database.LockLoginThrottleParams{
+			LockedUntil: sql.NullTime{Time: time.Now().UTC().Add(maxAccessTokenSeconds * time.Second), Valid: true},
+			Key:         sessionKey,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = q.RevokeSession(r.Context(), database.RevokeSessionParams{
+			FamilyID: claims.SessionID,
+			UserID:   user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("%d wrong codes in one session", attempts)
+		return recordSecurityEvent(r.Context(), q, r, uuid.NullUUID{UUID: user.ID, Valid: true}, securityEventMFAFailed, detail)
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return false
+	}
+	if !lockedUntil.IsZero() {
+		writeLoginLocked(w, lockedUntil)
+		return false
+	}
+	if !verified {
+		if signedOut {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Too many wrong codes, log in again"}`))
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "Invalid code"}`))
+		return false
+	}
+	return true
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	type recoveryCodesResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(recoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
@@ -0,0 +1,620 @@
+package apiConfig
+
+import (
+	"net/http"
+	"net/url"
+	"encoding/json"
+	"fmt"
+	"time"
+	"context"
+	"errors"
+	"slices"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/oauth"
+)
+
+const (
+	oauthCodeTTL = 2 * time.Minute
+	// oauthAccessTokenSeconds is how long client access tokens last, the
+	// same as the ones from a login.
+	oauthAccessTokenSeconds = 3600
+
+	oauthConsentPage = "/app/consent.html"
+)
+
+// oauthError is an error in the format of RFC 6749. Redirectable errors
+// are reported to the client through its redirect URI; the others mean the
+// redirect URI itself can't be trusted.
+type oauthError struct {
+	Code         string
+	Description  string
+	Redirectable bool
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+func hashOAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authorizeRequest holds the parameters of an authorization request. The
+// consent page posts them back unchanged once the user has decided.
+type authorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+func authorizeRequestFromQuery(query url.Values) authorizeRequest {
+	return authorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+}
+
+// authorization is a checked authorization request.
+type authorization struct {
+	client      database.OauthClient
+	redirectURI string
+	scopes      []string
+}
+
+// checkAuthorizeRequest validates an authorization request against the
+// client's registration. Errors are *oauthError unless the database failed.
+func (cfg *ApiConfig) checkAuthorizeRequest(ctx context.Context, a authorizeRequest) (authorization, error) {
+	clientId, err := uuid.Parse(a.ClientID)
+	if err != nil {
+		return authorization{}, &oauthError{Code: oauth.ErrInvalidRequest, Description: "unknown client_id"}
+	}
+	client, err := cfg.Database.GetOAuthClient(ctx, clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return authorization{}, &oauthError{Code: oauth.ErrInvalidRequest, Description: "unknown client_id"}
+	}
+	if err != nil {
+		return authorization{}, err
+	}
+
+	// Redirect URIs must match a registered one exactly. It may only be
+	// left out when there is just one.
+	redirectURI := a.RedirectURI
+	if len(redirectURI) == 0 && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorization{}, &oauthError{Code: oauth.ErrInvalidRequest, Description: "redirect_uri is not registered for this client"}
+	}
+
+	// From here on errors can be reported through the redirect URI.
+	authz := authorization{client: client, redirectURI: redirectURI}
+
+	if a.ResponseType != "code" {
+		return authz, &oauthError{Code: oauth.ErrUnsupportedResponseType, Description: "response_type must be code", Redirectable: true}
+	}
+	if a.CodeChallengeMethod != oauth.ChallengeMethodS256 || !oauth.ValidChallenge(a.CodeChallenge) {
+		return authz, &oauthError{Code: oauth.ErrInvalidRequest, Description: "PKCE with code_challenge_method S256 is required", Redirectable: true}
+	}
+
+	// Leaving out scope asks for everything the client registered for.
+	authz.scopes = client.Scopes
+	if requested := oauth.ParseScope(a.Scope); len(requested) > 0 {
+		scopes, err := auth.NormalizeScopes(requested)
+		if err != nil {
+			return authz, &oauthError{Code: oauth.ErrInvalidScope, Description: err.Error(), Redirectable: true}
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(client.Scopes, scope) {
+				return authz, &oauthError{Code: oauth.ErrInvalidScope, Description: fmt.Sprintf("client is not registered for %s", scope), Redirectable: true}
+			}
+		}
+		authz.scopes = scopes
+	}
+
+	return authz, nil
+}
+
+// errorRedirect is where to send the user agent to report err to the
+// client.
+func errorRedirect(redirectURI string, e *oauthError, state string) string {
+	params := url.Values{"error": {e.Code}, "error_description": {e.Description}}
+	if len(state) > 0 {
+		params.Set("state", state)
+	}
+	return oauth.RedirectURL(redirectURI, params)
+}
+
+// Authorize is where clients send users to ask for access. Valid requests
+// are passed on to the consent page with the same query.
+func (cfg *ApiConfig) Authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	a := authorizeRequestFromQuery(r.URL.Query())
+	authz, err := cfg.checkAuthorizeRequest(r.Context(), a)
+	var oerr *oauthError
+	if errors.As(err, &oerr) {
+		if !oerr.Redirectable {
+			writeOAuthError(w, http.StatusBadRequest, oerr.Code, oerr.Description)
+			return
+		}
+		http.Redirect(w, r, errorRedirect(authz.redirectURI, oerr, a.State), http.StatusFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	http.Redirect(w, r, oauthConsentPage+"?"+r.URL.RawQuery, http.StatusFound)
+}
+
+// ApproveAuthorization records the signed-in user's answer on the consent
+// page. It replies with the URL to send the browser to, which carries
+// either an authorization code or access_denied back to the client.
+func (cfg *ApiConfig) ApproveAuthorization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var a struct {
+		authorizeRequest
+		Approve bool `json:"approve"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauth.ErrInvalidRequest, "invalid request body")
+		return
+	}
+
+	authz, err := cfg.checkAuthorizeRequest(r.Context(), a.authorizeRequest)
+	var oerr *oauthError
+	if errors.As(err, &oerr) && !oerr.Redirectable {
+		writeOAuthError(w, http.StatusBadRequest, oerr.Code, oerr.Description)
+		return
+	}
+	if err != nil && oerr == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if oerr == nil && !a.Approve {
+		oerr = &oauthError{Code: oauth.ErrAccessDenied, Description: "the user denied the request"}
+	}
+
+	var redirectTo string
+	if oerr != nil {
+		redirectTo = errorRedirect(authz.redirectURI, oerr, a.State)
+	} else {
+		code, err := auth.MakeRefreshToken()
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Code"` + err.Error()))
+			return
+		}
+		err = cfg.Database.CreateOAuthCode(r.Context(), database.CreateOAuthCodeParams{
+			CodeHash:      hashOAuthToken(code),
+			ClientID:      authz.client.ID,
+			UserID:        claims.UserID,
+			RedirectUri:   authz.redirectURI,
+			Scopes:        authz.scopes,
+			CodeChallenge: a.CodeChallenge,
+			ExpiresAt:     time.Now().UTC().Add(oauthCodeTTL),
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+			return
+		}
+		params := url.Values{"code": {code}}
+		if len(a.State) > 0 {
+			params.Set("state", a.State)
+		}
+		redirectTo = oauth.RedirectURL(authz.redirectURI, params)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"redirect_to": redirectTo})
+}
+
+var errInvalidOAuthClient = errors.New("client authentication failed")
+
+// authenticateOAuthClient identifies the client calling a token endpoint,
+// from HTTP Basic auth or the client_id and client_secret form fields.
+// Confidential clients must present their secret.
+func (cfg *ApiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 form-encodes the credentials before Basic encoding them.
+		var err error
+		if id, err = url.QueryUnescape(id); err != nil {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	clientId, err := uuid.Parse(id)
+	if err != nil {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	client, err := cfg.Database.GetOAuthClient(r.Context(), clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.SecretHash.Valid && subtle.ConstantTimeCompare([]byte(hashOAuthToken(secret)), []byte(client.SecretHash.String)) != 1 {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	return client, nil
+}
+
+// tokenEndpointClient parses a token endpoint request and authenticates
+// its client, writing the error response itself when that fails.
+func (cfg *ApiConfig) tokenEndpointClient(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauth.ErrInvalidRequest, "invalid form body")
+		return database.OauthClient{}, false
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if errors.Is(err, errInvalidOAuthClient) {
+		writeOAuthError(w, http.StatusUnauthorized, oauth.ErrInvalidClient, err.Error())
+		return database.OauthClient{}, false
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+var errInvalidOAuthGrantRequest = &oauthError{Code: oauth.ErrInvalidGrant, Description: "invalid, expired or revoked grant"}
+
+// Token exchanges an authorization code or a refresh token for an access
+// token and a new refresh token.
+func (cfg *ApiConfig) Token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	client, ok := cfg.tokenEndpointClient(w, r)
+	if !ok {
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Token"` + err.Error()))
+		return
+	}
+	refreshExpiresAt := time.Now().UTC().Add(refreshTokenTTL)
+
+	var grant database.OauthGrant
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		grant, err = cfg.exchangeOAuthCode(r, client, refreshToken, refreshExpiresAt)
+	case "refresh_token":
+		grant, err = cfg.refreshOAuthGrant(r, client, refreshToken, refreshExpiresAt)
+	default:
+		writeOAuthError(w, http.StatusBadRequest, oauth.ErrUnsupportedGrantType, "grant_type must be authorization_code or refresh_token")
+		return
+	}
+	var oerr *oauthError
+	if errors.As(err, &oerr) {
+		writeOAuthError(w, http.StatusBadRequest, oerr.Code, oerr.Description)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	accessToken, err := cfg.Keys.MakeClientJWT(grant.UserID, grant.ID, grant.ClientID, grant.Scopes, time.Duration(oauthAccessTokenSeconds))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- New Token"` + err.Error()))
+		return
+	}
+
+	resp := struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    oauthAccessTokenSeconds,
+		RefreshToken: refreshToken,
+		Scope:        oauth.FormatScope(grant.Scopes),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// exchangeOAuthCode turns an authorization code into a new grant. A code
+// can be exchanged once: presenting it again means it leaked, so the grant
+// it was exchanged for is revoked.
+func (cfg *ApiConfig) exchangeOAuthCode(r *http.Request, client database.OauthClient, refreshToken string, refreshExpiresAt time.Time) (database.OauthGrant, error) {
+	codeHash := hashOAuthToken(r.PostForm.Get("code"))
+	var grant database.OauthGrant
+	replayed := false
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		code, err := q.LockOAuthCode(r.Context(), codeHash)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidOAuthGrantRequest
+		}
+		if err != nil {
+			return err
+		}
+		if code.ClientID != client.ID {
+			return errInvalidOAuthGrantRequest
+		}
+		if code.UsedAt.Valid {
+			replayed = true
+			if !code.GrantID.Valid {
+				return nil
+			}
+			_, err := q.RevokeOAuthGrant(r.Context(), database.RevokeOAuthGrantParams{ID: code.GrantID.UUID, ClientID: client.ID})
+			if err != nil {
+				return err
+			}
+			detail := fmt.Sprintf("authorization code for client %s replayed; grant %s revoked", client.ID, code.GrantID.UUID)
+			return recordSecurityEvent(r.Context(), q, r, uuid.NullUUID{UUID: code.UserID, Valid: true}, securityEventRefreshTokenReuse, detail)
+		}
+		if !code.ExpiresAt.After(time.Now().UTC()) || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+			return errInvalidOAuthGrantRequest
+		}
+		if !oauth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			return &oauthError{Code: oauth.ErrInvalidGrant, Description: "code_verifier does not match the code_challenge"}
+		}
+
+		grant = database.OauthGrant{
+			ID:       uuid.New(),
+			ClientID: client.ID,
+			UserID:   code.UserID,
+			Scopes:   code.Scopes,
+		}
+		err = q.CreateOAuthGrant(r.Context(), database.CreateOAuthGrantParams{
+			ID:               grant.ID,
+			ClientID:         grant.ClientID,
+			UserID:           grant.UserID,
+			Scopes:           grant.Scopes,
+			RefreshTokenHash: hashOAuthToken(refreshToken),
+			RefreshExpiresAt: refreshExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		return q.MarkOAuthCodeUsed(r.Context(), database.MarkOAuthCodeUsedParams{
+			GrantID:  uuid.NullUUID{UUID: grant.ID, Valid: true},
+			CodeHash: codeHash,
+		})
+	})
+	if err != nil {
+		return database.OauthGrant{}, err
+	}
+	if replayed {
+		return database.OauthGrant{}, errInvalidOAuthGrantRequest
+	}
+	return grant, nil
+}
+
+// refreshOAuthGrant rotates a grant's refresh token. Presenting the token
+// it replaced means that one leaked, so the grant is revoked.
+func (cfg *ApiConfig) refreshOAuthGrant(r *http.Request, client database.OauthClient, refreshToken string, refreshExpiresAt time.Time) (database.OauthGrant, error) {
+	oldHash := hashOAuthToken(r.PostForm.Get("refresh_token"))
+	grant, err := cfg.Database.RotateOAuthRefreshToken(r.Context(), database.RotateOAuthRefreshTokenParams{
+		NewRefreshTokenHash: hashOAuthToken(refreshToken),
+		RefreshExpiresAt:    refreshExpiresAt,
+		RefreshTokenHash:    oldHash,
+		ClientID:            client.ID,
+	})
+	if err == nil {
+		return grant, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.OauthGrant{}, err
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		revoked, err := q.RevokeOAuthGrantByPreviousRefreshToken(r.Context(), database.RevokeOAuthGrantByPreviousRefreshTokenParams{
+			RefreshTokenHash: oldHash,
+			ClientID:         client.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("refresh token for client %s replayed; grant %s revoked", client.ID, revoked.ID)
+		return recordSecurityEvent(r.Context(), q, r, uuid.NullUUID{UUID: revoked.UserID, Valid: true}, securityEventRefreshTokenReuse, detail)
+	})
+	if err != nil {
+		return database.OauthGrant{}, err
+	}
+	return database.OauthGrant{}, errInvalidOAuthGrantRequest
+}
+
+// RevokeOAuthToken lets a client give up a refresh token or access token
+// (RFC 7009). Either one revokes the whole grant. Unknown tokens are not an
+// error, so the response never says whether the token was valid.
+func (cfg *ApiConfig) RevokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	client, ok := cfg.tokenEndpointClient(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if len(token) == 0 {
+		writeOAuthError(w, http.StatusBadRequest, oauth.ErrInvalidRequest, "token is required")
+		return
+	}
+
+	revoked, err := cfg.Database.RevokeOAuthGrantByRefreshToken(r.Context(), database.RevokeOAuthGrantByRefreshTokenParams{
+		RefreshTokenHash: hashOAuthToken(token),
+		ClientID:         client.ID,
+	})
+	if err == nil && revoked == 0 {
+		if claims, perr := cfg.Keys.ParseJWTClaims(token); perr == nil && claims.ClientID == client.ID {
+			_, err = cfg.Database.RevokeOAuthGrant(r.Context(), database.RevokeOAuthGrantParams{
+				ID:       claims.SessionID,
+				ClientID: client.ID,
+			})
+		}
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// IntrospectToken tells a resource server whether an access token is
+// active and what it may do (RFC 7662). Callers authenticate as a
+// confidential client. Only the ResourceServers can introspect any token;
+// other clients are told a token is inactive unless it was issued to them.
+func (cfg *ApiConfig) IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	client, ok := cfg.tokenEndpointClient(w, r)
+	if !ok {
+		return
+	}
+	if !client.SecretHash.Valid {
+		writeOAuthError(w, http.StatusUnauthorized, oauth.ErrInvalidClient, "only confidential clients can introspect tokens")
+		return
+	}
+
+	type introspectionResponse struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+	}
+	var resp introspectionResponse
+
+	token := r.PostForm.Get("token")
+	p, err := cfg.principalFromToken(r, token)
+	if err == nil && !slices.Contains(cfg.ResourceServers, client.ID) && (!p.ClientID.Valid || p.ClientID.UUID != client.ID) {
+		err = errInvalidOAuthGrant
+	}
+	if err == nil {
+		resp = introspectionResponse{
+			Active:    true,
+			Scope:     oauth.FormatScope(auth.Scopes),
+			Subject:   p.UserID.String(),
+			TokenType: "Bearer",
+		}
+		if p.delegated() {
+			resp.Scope = oauth.FormatScope(p.Scopes)
+		}
+		if p.ClientID.Valid {
+			resp.ClientID = p.ClientID.UUID.String()
+		}
+		if claims, err := cfg.Keys.ParseJWTClaims(token); err == nil {
+			resp.ExpiresAt = claims.ExpiresAt.Unix()
+		}
+	} else if errors.Is(err, errTokenLookup) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
@@ -0,0 +1,266 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"errors"
+	"strings"
+	"unicode/utf8"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/oauth"
+)
+
+const (
+	maxOAuthClientNameLength = 64
+	maxOAuthRedirectURIs     = 10
+)
+
+type oauthClientResponse struct {
+	ClientID     uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+	// ClientSecret is only ever returned when the client is registered.
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func newOAuthClientResponse(c database.OauthClient) oauthClientResponse {
+	return oauthClientResponse{
+		ClientID:     c.ID,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectUris,
+		Scopes:       c.Scopes,
+		Confidential: c.SecretHash.Valid,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// CreateOAuthClient registers a third-party app owned by the caller.
+// Confidential clients get a secret, shown only in this response; public
+// clients authenticate with PKCE alone.
+func (cfg *ApiConfig) CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var c struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&c)
+	name := strings.TrimSpace(c.Name)
+	if err != nil || len(name) == 0 || utf8.RuneCountInString(name) > maxOAuthClientNameLength {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "name is required and must be at most %d characters"}`, maxOAuthClientNameLength)))
+		return
+	}
+	if len(c.RedirectURIs) == 0 || len(c.RedirectURIs) > maxOAuthRedirectURIs {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "between 1 and %d redirect_uris are required"}`, maxOAuthRedirectURIs)))
+		return
+	}
+	for _, uri := range c.RedirectURIs {
+		if err := oauth.ValidateRedirectURI(uri); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf(`{"error": %q}`, err.Error())))
+			return
+		}
+	}
+
+	scopes, err := auth.NormalizeScopes(c.Scopes)
+	if err != nil || len(scopes) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "scopes must be one or more of %s"}`, strings.Join(auth.Scopes, ", "))))
+		return
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if c.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Secret"` + err.Error()))
+			return
+		}
+		secretHash = sql.NullString{String: hashOAuthToken(secret), Valid: true}
+	}
+
+	client, err := cfg.Database.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           uuid.New(),
+		UserID:       claims.UserID,
+		Name:         name,
+		SecretHash:   secretHash,
+		RedirectUris: c.RedirectURIs,
+		Scopes:       scopes,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := newOAuthClientResponse(client)
+	resp.ClientSecret = secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// GetOAuthClients lists the apps the caller has registered.
+func (cfg *ApiConfig) GetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	clients, err := cfg.Database.ListOAuthClients(r.Context(), claims.UserID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := make([]oauthClientResponse, 0, len(clients))
+	for _, client := range clients {
+		resp = append(resp, newOAuthClientResponse(client))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// GetOAuthClient returns what the consent page shows about an app. It is
+// public, so it leaves out the redirect URIs and owner.
+func (cfg *ApiConfig) GetOAuthClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	clientId, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid client ID"}`))
+		return
+	}
+
+	client, err := cfg.Database.GetOAuthClient(r.Context(), clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "OAuth client not found"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := struct {
+		ClientID uuid.UUID `json:"client_id"`
+		Name     string    `json:"name"`
+		Scopes   []string  `json:"scopes"`
+	}{
+		ClientID: client.ID,
+		Name:     client.Name,
+		Scopes:   client.Scopes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// DeleteOAuthClient unregisters one of the caller's apps. Every grant users
+// gave it stops working at once.
+func (cfg *ApiConfig) DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	clientId, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid client ID"}`))
+		return
+	}
+
+	revoked, err := cfg.Database.RevokeOAuthClient(r.Context(), database.RevokeOAuthClientParams{
+		ID:     clientId,
+		UserID: claims.UserID,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if revoked == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "OAuth client not found"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
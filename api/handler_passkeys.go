@@ -0,0 +1,513 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+	"bytes"
+	"errors"
+	"strings"
+	"unicode/utf8"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/throttle"
+	"github.com/samuelhamann/chirpy/internal/webauthn"
+)
+
+const (
+	passkeyPurposeRegister = "register"
+	passkeyPurposeLogin    = "login"
+	// passkeyChallengeTTL is how long the browser has to finish a ceremony,
+	// including the user finding their authenticator.
+	passkeyChallengeTTL  = 5 * time.Minute
+	maxPasskeyNameLength = 64
+	maxPasskeysPerUser   = 10
+)
+
+var (
+	errInvalidPasskeyChallenge = errors.New("invalid passkey challenge")
+	errInvalidPasskey          = errors.New("invalid passkey")
+)
+
+type passkeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newPasskeyResponse(c database.WebauthnCredential) passkeyResponse {
+	resp := passkeyResponse{
+		ID:        base64.RawURLEncoding.EncodeToString(c.ID),
+		Name:      c.Name,
+		CreatedAt: c.CreatedAt,
+	}
+	if c.LastUsedAt.Valid {
+		resp.LastUsedAt = &c.LastUsedAt.Time
+	}
+	return resp
+}
+
+// startPasskeyCeremony stores a new challenge for purpose and returns it
+// with the token the browser must send back to finish the ceremony.
+func (cfg *ApiConfig) startPasskeyCeremony(r *http.Request, userId uuid.NullUUID, purpose string) (string, []byte, time.Time, error) {
+	// Login challenges can be requested by anyone, so old ones are cleared
+	// out as new ones are made.
+	if err := cfg.Database.DeleteExpiredWebAuthnChallenges(r.Context()); err != nil {
+		return "", nil, time.Time{}, err
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+	expiresAt := time.Now().UTC().Add(passkeyChallengeTTL)
+	err = cfg.Database.CreateWebAuthnChallenge(r.Context(), database.CreateWebAuthnChallengeParams{
+		Token:     token,
+		UserID:    userId,
+		Purpose:   purpose,
+		Challenge: challenge,
+		ExpiresAt: expiresAt,
+	})
+	return token, challenge, expiresAt, err
+}
+
+// consumePasskeyChallenge deletes the challenge behind token, so each one is
+// only ever checked once, and returns it if it hasn't expired.
+func (cfg *ApiConfig) consumePasskeyChallenge(r *http.Request, token, purpose string) (database.WebauthnChallenge, error) {
+	challenge, err := cfg.Database.ConsumeWebAuthnChallenge(r.Context(), database.ConsumeWebAuthnChallengeParams{
+		Token:   token,
+		Purpose: purpose,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.WebauthnChallenge{}, errInvalidPasskeyChallenge
+	}
+	if err != nil {
+		return database.WebauthnChallenge{}, err
+	}
+	if !challenge.ExpiresAt.After(time.Now().UTC()) {
+		return database.WebauthnChallenge{}, errInvalidPasskeyChallenge
+	}
+	return challenge, nil
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create
+// to add a passkey to the signed-in user's account.
+func (cfg *ApiConfig) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	existing, err := cfg.Database.ListWebAuthnCredentials(r.Context(), user.ID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if len(existing) >= maxPasskeysPerUser {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(fmt.Sprintf(`{"error": "You can have at most %d passkeys"}`, maxPasskeysPerUser)))
+		return
+	}
+	exclude := make([][]byte, 0, len(existing))
+	for _, c := range existing {
+		exclude = append(exclude, c.ID)
+	}
+
+	token, challenge, expiresAt, err := cfg.startPasskeyCeremony(r, uuid.NullUUID{UUID: user.ID, Valid: true}, passkeyPurposeRegister)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	// The user handle is the account ID, which says nothing about the user
+	// to anyone who reads it off the authenticator.
+	name := user.Email
+	if user.Handle.Valid {
+		name = "@" + user.Handle.String
+	}
+	type beginResponse struct {
+		PasskeyToken string                   `json:"passkey_token"`
+		ExpiresAt    time.Time                `json:"expires_at"`
+		PublicKey    webauthn.CreationOptions `json:"publicKey"`
+	}
+	resp := beginResponse{
+		PasskeyToken: token,
+		ExpiresAt:    expiresAt,
+		PublicKey:    cfg.WebAuthn.CreationOptions(challenge, user.ID[:], name, exclude, passkeyChallengeTTL),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// FinishPasskeyRegistration verifies the new credential and saves it.
+func (cfg *ApiConfig) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var p struct {
+		PasskeyToken string                          `json:"passkey_token"`
+		Name         string                          `json:"name"`
+		Credential   webauthn.RegistrationCredential `json:"credential"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil || len(p.PasskeyToken) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "passkey_token and credential are required"}`))
+		return
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if len(p.Name) == 0 {
+		p.Name = "Passkey"
+	}
+	if utf8.RuneCountInString(p.Name) > maxPasskeyNameLength {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Name must be at most %d characters"}`, maxPasskeyNameLength)))
+		return
+	}
+
+	challenge, err := cfg.consumePasskeyChallenge(r, p.PasskeyToken, passkeyPurposeRegister)
+	if err == nil && challenge.UserID.UUID != claims.UserID {
+		err = errInvalidPasskeyChallenge
+	}
+	if errors.Is(err, errInvalidPasskeyChallenge) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid or expired passkey token"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	cred, err := cfg.WebAuthn.VerifyRegistration(challenge.Challenge, p.Credential.Response)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Passkey could not be verified"}`))
+		return
+	}
+
+	stored, err := cfg.Database.CreateWebAuthnCredential(r.Context(), database.CreateWebAuthnCredentialParams{
+		ID:        cred.ID,
+		UserID:    claims.UserID,
+		PublicKey: cred.PublicKey,
+		SignCount: int64(cred.SignCount),
+		Name:      p.Name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "This passkey is already registered"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newPasskeyResponse(stored)); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) GetPasskeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	credentials, err := cfg.Database.ListWebAuthnCredentials(r.Context(), claims.UserID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := make([]passkeyResponse, 0, len(credentials))
+	for _, c := range credentials {
+		resp = append(resp, newPasskeyResponse(c))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	credentialId, err := base64.RawURLEncoding.DecodeString(r.PathValue("credentialID"))
+	if err != nil || len(credentialId) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid passkey ID"}`))
+		return
+	}
+
+	deleted, err := cfg.Database.DeleteWebAuthnCredential(r.Context(), database.DeleteWebAuthnCredentialParams{
+		ID:     credentialId,
+		UserID: claims.UserID,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if deleted == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Passkey not found"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// passkeyLoginKeys are the throttle keys for a passkey login. There is no
+// email to count failures against, only the client address.
+func passkeyLoginKeys(r *http.Request) []loginKey {
+	return []loginKey{{key: throttle.IPKey(clientIP(r)), policy: throttle.IPPolicy}}
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get. No
+// credentials are listed, so the browser offers any passkey it has for
+// Chirpy and the response says whose account it belongs to.
+func (cfg *ApiConfig) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	lockedUntil, err := loginLockedUntil(r.Context(), cfg.Database, passkeyLoginKeys(r))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if !lockedUntil.IsZero() {
+		writeLoginLocked(w, lockedUntil)
+		return
+	}
+
+	token, challenge, expiresAt, err := cfg.startPasskeyCeremony(r, uuid.NullUUID{}, passkeyPurposeLogin)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	type beginResponse struct {
+		PasskeyToken string                  `json:"passkey_token"`
+		ExpiresAt    time.Time               `json:"expires_at"`
+		PublicKey    webauthn.RequestOptions `json:"publicKey"`
+	}
+	resp := beginResponse{
+		PasskeyToken: token,
+		ExpiresAt:    expiresAt,
+		PublicKey:    cfg.WebAuthn.RequestOptions(challenge, nil, passkeyChallengeTTL),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// FinishPasskeyLogin verifies the passkey assertion and issues the same
+// tokens as LoginUser. Passkeys always verify the user with a PIN or
+// biometric, so they count as both factors and skip any TOTP challenge.
+func (cfg *ApiConfig) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	var p struct {
+		PasskeyToken     string                       `json:"passkey_token"`
+		Credential       webauthn.AssertionCredential `json:"credential"`
+		ExpiresInSeconds int64                        `json:"expires_in_seconds"`
+		UseCookies       bool                         `json:"use_cookies"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil || len(p.PasskeyToken) == 0 || len(p.Credential.RawID) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "passkey_token and credential are required"}`))
+		return
+	}
+
+	keys := passkeyLoginKeys(r)
+	lockedUntil, err := loginLockedUntil(r.Context(), cfg.Database, keys)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if !lockedUntil.IsZero() {
+		writeLoginLocked(w, lockedUntil)
+		return
+	}
+
+	challenge, err := cfg.consumePasskeyChallenge(r, p.PasskeyToken, passkeyPurposeLogin)
+	if errors.Is(err, errInvalidPasskeyChallenge) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid or expired passkey token"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	var user database.User
+	userId := uuid.NullUUID{}
+	cloned := false
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		// Locking the credential keeps concurrent logins from racing on
+		// the signature counter.
+		cred, err := q.LockWebAuthnCredential(r.Context(), p.Credential.RawID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidPasskey
+		}
+		if err != nil {
+			return err
+		}
+		userId = uuid.NullUUID{UUID: cred.UserID, Valid: true}
+		if len(p.Credential.Response.UserHandle) > 0 && !bytes.Equal(p.Credential.Response.UserHandle, cred.UserID[:]) {
+			return errInvalidPasskey
+		}
+
+		signCount, err := cfg.WebAuthn.VerifyAssertion(challenge.Challenge, cred.PublicKey, uint32(cred.SignCount), p.Credential.Response)
+		if errors.Is(err, webauthn.ErrSignCount) {
+			cloned = true
+			return errInvalidPasskey
+		}
+		if err != nil {
+			return errInvalidPasskey
+		}
+		err = q.UpdateWebAuthnSignCount(r.Context(), database.UpdateWebAuthnSignCountParams{
+			SignCount: int64(signCount),
+			ID:        cred.ID,
+		})
+		if err != nil {
+			return err
+		}
+		user, err = q.GetUserByID(r.Context(), cred.UserID)
+		return err
+	})
+	if errors.Is(err, errInvalidPasskey) {
+		if cloned {
+			detail := fmt.Sprintf("passkey %s signature counter went backwards", base64.RawURLEncoding.EncodeToString(p.Credential.RawID))
+			if err := recordSecurityEvent(r.Context(), cfg.Database, r, userId, securityEventPasskeyCloned, detail); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+				return
+			}
+		}
+		if err := recordLoginFailure(r.Context(), cfg.Database, r, keys, userId); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Passkey not recognized"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	if err := clearLoginFailures(r.Context(), cfg.Database, user.Email); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	cfg.completeLogin(w, r, user, accessTokenSeconds(p.ExpiresInSeconds), p.UseCookies)
+}
@@ -0,0 +1,138 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"context"
+	"time"
+	"errors"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/profile"
+)
+
+// profileResponse is the public view of a user. It must never carry the
+// email address or password hash.
+type profileResponse struct {
+	ID          uuid.UUID           `json:"id"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Handle      *string             `json:"handle"`
+	DisplayName string              `json:"display_name"`
+	Bio         string              `json:"bio"`
+	Avatar      *attachmentResponse `json:"avatar"`
+	IsChirpyRed bool                `json:"is_chirpy_red"`
+}
+
+// userResponse is what account owners see about themselves.
+type userResponse struct {
+	profileResponse
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+}
+
+func (cfg *ApiConfig) newProfileResponse(ctx context.Context, user database.User) (profileResponse, error) {
+	resp := profileResponse{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+	}
+	isChirpyRed, err := cfg.isChirpyRed(ctx, user.ID)
+	if err != nil {
+		return profileResponse{}, err
+	}
+	resp.IsChirpyRed = isChirpyRed
+	if user.Handle.Valid {
+		resp.Handle = &user.Handle.String
+	}
+	if user.AvatarAttachmentID.Valid {
+		attachment, err := cfg.Database.GetAttachment(ctx, user.AvatarAttachmentID.UUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return profileResponse{}, err
+		}
+		if err == nil {
+			avatar := cfg.newAttachmentResponse(attachment)
+			resp.Avatar = &avatar
+		}
+	}
+	return resp, nil
+}
+
+func (cfg *ApiConfig) newUserResponse(ctx context.Context, user database.User) (userResponse, error) {
+	public, err := cfg.newProfileResponse(ctx, user)
+	if err != nil {
+		return userResponse{}, err
+	}
+	return userResponse{
+		profileResponse: public,
+		UpdatedAt:       user.UpdatedAt,
+		Email:           user.Email,
+		EmailVerified:   user.EmailVerifiedAt.Valid,
+		MFAEnabled:      user.TotpEnabledAt.Valid,
+	}, nil
+}
+
+// isHandleTaken reports whether err came from the case-insensitive unique
+// index on handles.
+func isHandleTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_handle_lower_idx"
+}
+
+// GetUserProfile looks a user up by ID or by handle, with or without the @.
+func (cfg *ApiConfig) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	handleOrID := r.PathValue("handleOrID")
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(handleOrID); parseErr == nil {
+		user, err = cfg.Database.GetUserByID(r.Context(), id)
+	} else {
+		user, err = cfg.Database.GetUserByHandle(r.Context(), profile.NormalizeHandle(handleOrID))
+	}
+	// A block either way makes the user look like they don't exist.
+	if err == nil {
+		var blocked bool
+		blocked, err = cfg.blockedBetween(r.Context(), cfg.viewerID(r), user.ID)
+		if err == nil && blocked {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "User not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp, err := cfg.newProfileResponse(r.Context(), user)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
@@ -0,0 +1,345 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"context"
+	"errors"
+	"log"
+	"time"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/entitlement"
+)
+
+type scheduledChirpResponse struct {
+	ID            uuid.UUID     `json:"id"`
+	Body          string        `json:"body"`
+	InReplyToID   uuid.NullUUID `json:"in_reply_to_id"`
+	AttachmentIDs []uuid.UUID   `json:"attachment_ids"`
+	PublishAt     time.Time     `json:"publish_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+	// Failure says why the chirp could not be posted. It is empty while
+	// the chirp is waiting.
+	Failure string `json:"failure,omitempty"`
+}
+
+func newScheduledChirpResponse(scheduled database.ScheduledChirp) scheduledChirpResponse {
+	resp := scheduledChirpResponse{
+		ID:            scheduled.ID,
+		Body:          scheduled.Body,
+		InReplyToID:   scheduled.InReplyToID,
+		AttachmentIDs: scheduled.AttachmentIds,
+		PublishAt:     scheduled.PublishAt,
+		CreatedAt:     scheduled.CreatedAt,
+		Failure:       scheduled.Failure,
+	}
+	if resp.AttachmentIDs == nil {
+		resp.AttachmentIDs = []uuid.UUID{}
+	}
+	return resp
+}
+
+// scheduleChirp finishes CreateChirp for a chirp with a publish_at, once the
+// body, attachments and parent have been checked.
+func (cfg *ApiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, entitlements entitlement.Entitlements, userId uuid.UUID, body string, inReplyToID uuid.NullUUID, attachmentIds []uuid.UUID, publishAt time.Time) {
+	// The media is only claimed when the chirp is posted, but a chirp that
+	// can't be posted as things stand is turned away now.
+	if len(attachmentIds) > 0 {
+		attachable, err := cfg.Database.CountAttachableMedia(r.Context(), database.CountAttachableMediaParams{
+			Ids:    attachmentIds,
+			UserID: userId,
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+			return
+		}
+		if int(attachable) != len(attachmentIds) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Unknown or already attached media"}`))
+			return
+		}
+	}
+
+	// The posting lock taken by checkChirpRate also keeps the pending count
+	// right while the new chirp is added.
+	var scheduled database.ScheduledChirp
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		if err := checkChirpRate(r.Context(), q, entitlements, userId); err != nil {
+			return err
+		}
+		pending, err := q.CountPendingScheduledChirps(r.Context(), userId)
+		if err != nil {
+			return err
+		}
+		if err := entitlements.CheckSchedule(publishAt, time.Now().UTC(), int(pending)); err != nil {
+			return err
+		}
+		scheduled, err = q.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+			ID:            uuid.New(),
+			UserID:        userId,
+			Body:          body,
+			InReplyToID:   inReplyToID,
+			AttachmentIds: attachmentIds,
+			PublishAt:     publishAt,
+		})
+		return err
+	})
+	if errors.Is(err, entitlement.ErrRateLimited) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+	if errors.Is(err, entitlement.ErrScheduleNotAllowed) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+	if errors.Is(err, entitlement.ErrScheduleInPast) || errors.Is(err, entitlement.ErrScheduleTooFarAhead) || errors.Is(err, entitlement.ErrTooManyScheduled) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(newScheduledChirpResponse(scheduled)); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// GetScheduledChirps lists the caller's scheduled chirps, soonest first,
+// including any that failed to post.
+func (cfg *ApiConfig) GetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	scheduled, err := cfg.Database.ListScheduledChirps(r.Context(), p.UserID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	resp := make([]scheduledChirpResponse, 0, len(scheduled))
+	for _, s := range scheduled {
+		resp = append(resp, newScheduledChirpResponse(s))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+// DeleteScheduledChirp cancels a scheduled chirp before it is posted.
+func (cfg *ApiConfig) DeleteScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	p, ok := cfg.authenticate(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	scheduledId, err := uuid.Parse(r.PathValue("scheduledID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid scheduled chirp ID"}`))
+		return
+	}
+
+	deleted, err := cfg.Database.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     scheduledId,
+		UserID: p.UserID,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if deleted == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Scheduled chirp not found"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunScheduledChirps posts scheduled chirps as they fall due, checking
+// immediately and then every interval until ctx is cancelled.
+func (cfg *ApiConfig) RunScheduledChirps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.publishDueChirps(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("scheduled chirps: publish failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDueChirps posts every scheduled chirp that is due, one transaction
+// each. Rows are claimed with SKIP LOCKED so several instances can run this
+// at once without posting anything twice.
+func (cfg *ApiConfig) publishDueChirps(ctx context.Context) error {
+	for {
+		var scheduled database.ScheduledChirp
+		done := false
+		err := cfg.withTx(ctx, func(q *database.Queries) error {
+			var err error
+			scheduled, err = q.ClaimDueScheduledChirp(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				done = true
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := cfg.checkScheduledChirp(ctx, scheduled); err != nil {
+				return err
+			}
+			_, err = postChirp(ctx, q, scheduled.UserID, scheduled.Body, scheduled.InReplyToID, scheduled.AttachmentIds)
+			if err != nil {
+				return err
+			}
+			_, err = q.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{
+				ID:     scheduled.ID,
+				UserID: scheduled.UserID,
+			})
+			return err
+		})
+		// A chirp that failed for any reason that will not go away by
+		// itself, such as media that has since been used or an author who
+		// has lost Chirpy Red, is marked failed rather than retried
+		// forever, and the rest are still posted. Only a transient error
+		// stops the run until the next tick.
+		if err != nil && scheduled.ID != uuid.Nil && !isTransient(err) {
+			log.Printf("scheduled chirps: %s can't be posted: %v", scheduled.ID, err)
+			err = cfg.Database.FailScheduledChirp(ctx, database.FailScheduledChirpParams{
+				Failure: scheduledChirpFailure(err),
+				ID:      scheduled.ID,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// errParentUnavailable means a scheduled reply's parent was deleted, or is
+// on the other side of a block, by the time the reply fell due.
+var errParentUnavailable = errors.New("parent chirp not found")
+
+// checkScheduledChirp repeats the checks CreateChirp made when the chirp
+// was scheduled, since the author may have lost Chirpy Red or been blocked
+// by the parent's author since.
+func (cfg *ApiConfig) checkScheduledChirp(ctx context.Context, scheduled database.ScheduledChirp) error {
+	entitlements, err := cfg.entitlementsFor(ctx, scheduled.UserID)
+	if err != nil {
+		return err
+	}
+	if !entitlements.CanSchedule {
+		return entitlement.ErrScheduleNotAllowed
+	}
+	if err := entitlements.CheckChirp(scheduled.Body, len(scheduled.AttachmentIds)); err != nil {
+		return err
+	}
+	if scheduled.InReplyToID.Valid {
+		_, err := cfg.getVisibleChirp(ctx, uuid.NullUUID{UUID: scheduled.UserID, Valid: true}, scheduled.InReplyToID.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errParentUnavailable
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduledChirpFailure is the reason shown to the author of a scheduled
+// chirp that couldn't be posted. Database errors are not spelled out.
+func scheduledChirpFailure(err error) string {
+	switch {
+	case errors.Is(err, errInvalidAttachments):
+		return "Unknown or already attached media"
+	case errors.Is(err, errParentUnavailable):
+		return "Parent chirp not found"
+	case errors.Is(err, entitlement.ErrScheduleNotAllowed), errors.Is(err, entitlement.ErrEmptyChirp),
+		errors.Is(err, entitlement.ErrChirpTooLong), errors.Is(err, entitlement.ErrTooManyAttachments):
+		return err.Error()
+	}
+	return "The chirp could not be posted"
+}
+
+// isTransient reports whether err is down to the database being unreachable
+// or busy, so that the same work could succeed if tried again later.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Connection failures, serialization failures and deadlocks,
+		// running out of resources, and the server shutting down.
+		switch pqErr.Code.Class() {
+		case "08", "40", "53", "57", "58":
+			return true
+		}
+		return pqErr.Code == "55P03"
+	}
+	return false
+}
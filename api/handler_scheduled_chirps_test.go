@@ -0,0 +1,112 @@
+package apiConfig
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Cancelled", err: context.Canceled, want: true},
+		{name: "Bad connection", err: fmt.Errorf("claim: %w", driver.ErrBadConn), want: true},
+		{name: "Connection failure", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "Deadlock", err: &pq.Error{Code: "40P01"}, want: true},
+		{name: "Shutting down", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "Foreign key violation", err: &pq.Error{Code: "23503"}, want: false},
+		{name: "Invalid text", err: &pq.Error{Code: "22021"}, want: false},
+		{name: "Invalid attachments", err: errInvalidAttachments, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func createDueChirp(t *testing.T, cfg *ApiConfig, userID uuid.UUID, body string, attachmentIDs []uuid.UUID, inReplyTo uuid.NullUUID) database.ScheduledChirp {
+	t.Helper()
+	scheduled, err := cfg.Database.CreateScheduledChirp(context.Background(), database.CreateScheduledChirpParams{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Body:          body,
+		InReplyToID:   inReplyTo,
+		AttachmentIds: attachmentIDs,
+		PublishAt:     time.Now().UTC().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("CreateScheduledChirp() error = %v", err)
+	}
+	return scheduled
+}
+
+func TestPublishDueChirpsSkipsFailures(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "planner")
+	broken := createDueChirp(t, cfg, user.ID, "with missing media", []uuid.UUID{uuid.New()}, uuid.NullUUID{})
+	createDueChirp(t, cfg, user.ID, "on time", nil, uuid.NullUUID{})
+
+	if err := cfg.publishDueChirps(context.Background()); err != nil {
+		t.Fatalf("publishDueChirps() error = %v", err)
+	}
+
+	left, err := cfg.Database.ListScheduledChirps(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("ListScheduledChirps() error = %v", err)
+	}
+	if len(left) != 1 || left[0].ID != broken.ID || len(left[0].Failure) == 0 {
+		t.Errorf("ListScheduledChirps() = %+v, want only %s, failed, with the other posted", left, broken.ID)
+	}
+}
+
+func TestPublishDueChirpsRechecks(t *testing.T) {
+	cfg := newTestConfig(t)
+	red := createTestUser(t, cfg, "red")
+	makeChirpyRed(t, cfg, red.ID)
+	lapsed := createTestUser(t, cfg, "lapsed")
+	parentAuthor := createTestUser(t, cfg, "parent")
+	parent := createTestChirp(t, cfg, parentAuthor.ID, "hello", uuid.NullUUID{})
+
+	tooLong := createDueChirp(t, cfg, lapsed.ID, strings.Repeat("x", 500), nil, uuid.NullUUID{})
+	blockedReply := createDueChirp(t, cfg, red.ID, "replying", nil, uuid.NullUUID{UUID: parent.ID, Valid: true})
+	err := cfg.Database.BlockUser(context.Background(), database.BlockUserParams{BlockerID: parentAuthor.ID, BlockedID: red.ID})
+	if err != nil {
+		t.Fatalf("BlockUser() error = %v", err)
+	}
+
+	if err := cfg.publishDueChirps(context.Background()); err != nil {
+		t.Fatalf("publishDueChirps() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		userID uuid.UUID
+		id     uuid.UUID
+	}{
+		{name: "No longer Chirpy Red", userID: lapsed.ID, id: tooLong.ID},
+		{name: "Parent author blocked the replier", userID: red.ID, id: blockedReply.ID},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			left, err := cfg.Database.ListScheduledChirps(context.Background(), tt.userID)
+			if err != nil {
+				t.Fatalf("ListScheduledChirps() error = %v", err)
+			}
+			if len(left) != 1 || left[0].ID != tt.id || len(left[0].Failure) == 0 {
+				t.Errorf("ListScheduledChirps() = %+v, want %s kept as failed", left, tt.id)
+			}
+		})
+	}
+}
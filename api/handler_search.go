@@ -0,0 +1,227 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/pagination"
+	"github.com/samuelhamann/chirpy/internal/search"
+)
+
+type searchChirpResult struct {
+	chirpResponse
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchUserResult struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+}
+
+func (cfg *ApiConfig) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	query := r.URL.Query()
+	q := query.Get("q")
+	if len(q) == 0 || len(q) > 256 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "q must be between 1 and 256 characters"}`))
+		return
+	}
+
+	page, err := pagination.ParseOffsetPage(query)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
+		return
+	}
+
+	switch query.Get("type") {
+	case "", "chirps":
+		cfg.searchChirps(w, r, q, page)
+	case "users":
+		cfg.searchUsers(w, r, q, page)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "type must be chirps or users"}`))
+	}
+}
+
+func (cfg *ApiConfig) searchChirps(w http.ResponseWriter, r *http.Request, q string, page pagination.OffsetPage) {
+	query := r.URL.Query()
+
+	tsquery, err := search.BuildTSQuery(q)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return
+	}
+
+	var authorID uuid.NullUUID
+	if raw := query.Get("author_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid author_id"}`))
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var since, until sql.NullTime
+	for _, bound := range []struct {
+		name string
+		dst  *sql.NullTime
+	}{{"since", &since}, {"until", &until}} {
+		raw := query.Get(bound.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf(`{"error": "%s must be an RFC 3339 timestamp"}`, bound.name)))
+			return
+		}
+		*bound.dst = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+
+	viewer := cfg.viewerID(r)
+	rows, err := cfg.Database.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Tsquery:    tsquery,
+		AuthorID:   authorID,
+		Since:      since,
+		Until:      until,
+		ViewerID:   viewer,
+		PageLimit:  int32(page.Limit + 1),
+		PageOffset: int32(page.Offset),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		nextCursor = pagination.EncodeOffset(page.Offset + page.Limit)
+	}
+
+	chirps := make([]chirpResponse, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, newChirpResponse(database.Chirp{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			InReplyToID:  row.InReplyToID,
+			DeletedAt:    row.DeletedAt,
+			LikeCount:    row.LikeCount,
+			RechirpCount: row.RechirpCount,
+			EditedAt:     row.EditedAt,
+		}))
+	}
+	err = cfg.decorateChirps(r.Context(), viewer, chirps)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	results := make([]searchChirpResult, 0, len(rows))
+	for i, row := range rows {
+		results = append(results, searchChirpResult{
+			chirpResponse: chirps[i],
+			Rank:          row.Rank,
+			Snippet:       search.HighlightHTML(row.Snippet),
+		})
+	}
+
+	type searchChirpsResponse struct {
+		Chirps     []searchChirpResult `json:"chirps"`
+		NextCursor string              `json:"next_cursor,omitempty"`
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(searchChirpsResponse{
+		Chirps:     results,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) searchUsers(w http.ResponseWriter, r *http.Request, q string, page pagination.OffsetPage) {
+	rows, err := cfg.Database.SearchUsers(r.Context(), database.SearchUsersParams{
+		Pattern:    search.LikePrefix(q),
+		ViewerID:   cfg.viewerID(r),
+		PageLimit:  int32(page.Limit + 1),
+		PageOffset: int32(page.Offset),
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		nextCursor = pagination.EncodeOffset(page.Offset + page.Limit)
+	}
+
+	// Email addresses are neither matched nor returned, so searching can't
+	// reveal who has an account.
+	users := make([]searchUserResult, 0, len(rows))
+	for _, row := range rows {
+		user := searchUserResult{ID: row.ID, CreatedAt: row.CreatedAt, DisplayName: row.DisplayName}
+		if row.Handle.Valid {
+			user.Handle = &row.Handle.String
+		}
+		users = append(users, user)
+	}
+
+	type searchUsersResponse struct {
+		Users      []searchUserResult `json:"users"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(searchUsersResponse{
+		Users:      users,
+		NextCursor: nextCursor,
+	})
+	if err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
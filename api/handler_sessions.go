@@ -0,0 +1,239 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"strings"
+	"unicode/utf8"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+const maxSessionNameLength = 64
+
+// A session is one login: the family of refresh tokens rotated from it. Its
+// ID is the family ID, which access tokens carry in their sid claim.
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// sessionClaims authenticates a caller signed in with an access token and
+// returns its claims, writing the error response itself when that fails.
+func (cfg *ApiConfig) sessionClaims(w http.ResponseWriter, r *http.Request) (auth.Claims, bool) {
+	tokenString, ok := requestAccessToken(w, r)
+	if !ok {
+		return auth.Claims{}, false
+	}
+	// Account settings are off limits to API tokens whatever their scopes,
+	// so a leaked token can't be used to take the account over.
+	if auth.IsAPIToken(tokenString) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "API tokens can't be used here, log in instead"}`))
+		return auth.Claims{}, false
+	}
+
+	claims, err := cfg.Keys.ParseJWTClaims(tokenString)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid token: %v"}`, err)))
+		return auth.Claims{}, false
+	}
+	if claims.ClientID != uuid.Nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "OAuth clients can't be used here, log in instead"}`))
+		return auth.Claims{}, false
+	}
+	return claims, true
+}
+
+func (cfg *ApiConfig) GetSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Database.ListSessions(r.Context(), claims.UserID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	sessions := make([]sessionResponse, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, sessionResponse{
+			ID:         row.FamilyID,
+			Name:       row.Name,
+			UserAgent:  row.UserAgent,
+			IPAddress:  row.IpAddress,
+			StartedAt:  row.StartedAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+			Current:    row.FamilyID == claims.SessionID,
+		})
+	}
+
+	type sessionsResponse struct {
+		Sessions []sessionResponse `json:"sessions"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(sessionsResponse{Sessions: sessions}); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
+
+func (cfg *ApiConfig) RenameSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessionId, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid session ID"}`))
+		return
+	}
+
+	var s struct {
+		Name string `json:"name"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&s)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request body"}`))
+		return
+	}
+	name := strings.TrimSpace(s.Name)
+	if utf8.RuneCountInString(name) > maxSessionNameLength {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Session names must be at most 64 characters"}`))
+		return
+	}
+
+	renamed, err := cfg.Database.RenameSession(r.Context(), database.RenameSessionParams{
+		Name:     name,
+		FamilyID: sessionId,
+		UserID:   claims.UserID,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if renamed == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Session not found"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessionId, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid session ID"}`))
+		return
+	}
+
+	revoked, err := cfg.Database.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionId,
+		UserID:   claims.UserID,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+	if revoked == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Session not found"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllSessions signs the user out everywhere except the session making
+// the request.
+func (cfg *ApiConfig) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	claims, ok := cfg.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := cfg.Database.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:       claims.UserID,
+		KeepFamilyID: uuid.NullUUID{UUID: claims.SessionID, Valid: claims.SessionID != uuid.Nil},
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf(`{"revoked": %d}`, revoked)))
+}
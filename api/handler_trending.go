@@ -0,0 +1,57 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"strconv"
+	"time"
+	"github.com/samuelhamann/chirpy/internal/trending"
+)
+
+// GetTrending serves the rankings cached by the background tracker; it never
+// queries the database itself.
+func (cfg *ApiConfig) GetTrending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	limit := 10
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "limit must be a positive integer"}`))
+			return
+		}
+		limit = n
+	}
+
+	trends, updatedAt := cfg.Trending.Top(limit)
+	trendingCfg := cfg.Trending.Config()
+
+	type trendingResponse struct {
+		Trends    []trending.Trend `json:"trends"`
+		Window    string           `json:"window"`
+		Baseline  string           `json:"baseline"`
+		UpdatedAt *time.Time       `json:"updated_at"`
+	}
+	resp := trendingResponse{
+		Trends:   trends,
+		Window:   trendingCfg.Window.String(),
+		Baseline: trendingCfg.Baseline.String(),
+	}
+	if !updatedAt.IsZero() {
+		resp.UpdatedAt = &updatedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
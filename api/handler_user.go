@@ -8,7 +8,28 @@ import (
 	"time"
 	"github.com/google/uuid"
 	"fmt"
+	"context"
+	"errors"
+	"database/sql"
+	"log"
+	"github.com/samuelhamann/chirpy/internal/profile"
+	"github.com/samuelhamann/chirpy/internal/password"
 )
+
+// maxAccessTokenSeconds bounds how long an access token lives. Access
+// tokens aren't checked against their session, so logging out or revoking
+// a session only takes full effect once they expire.
+const maxAccessTokenSeconds = 3600
+
+// accessTokenSeconds is the lifetime for a login that asked for requested
+// seconds: anything up to the maximum, which is also the default.
+func accessTokenSeconds(requested int64) int64 {
+	if requested <= 0 || requested > maxAccessTokenSeconds {
+		return maxAccessTokenSeconds
+	}
+	return requested
+}
+
 func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "application/json")
@@ -19,6 +40,7 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var u struct {
 		Email string `json:"email"`
 		Password string `json:"password"`
+		Handle string `json:"handle"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&u)
 
@@ -28,8 +50,23 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`"error": "Something went wrong -- email"`))
 		return
 	}
+
+	if !cfg.checkNewPassword(w, u.Password, u.Email) {
+		return
+	}
+
+	var handle sql.NullString
+	if len(u.Handle) > 0 {
+		if err := profile.ValidateHandle(u.Handle); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+			return
+		}
+		handle = sql.NullString{String: u.Handle, Valid: true}
+	}
 	
-	hashedPassword, err := auth.HashPassword(u.Password)
+	hashedPassword, err := cfg.Passwords.Hash(u.Password)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -40,19 +77,37 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 	createUserParams := database.CreateUserParams{
 		Email: u.Email,
 		HashedPassword: hashedPassword,
+		Handle: handle,
 	}
 
 	user, err := cfg.Database.CreateUser(r.Context(), createUserParams)
+	if isHandleTaken(err) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Handle is already taken"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("mail: verification for new user %s: %v", user.ID, err)
+	}
+
+	resp, err := cfg.newUserResponse(r.Context(), user)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
-	user.HashedPassword = "" // Clear hashed password before sending response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(user); err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
         w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
         return
     }
@@ -69,6 +124,9 @@ func (cfg *ApiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 		Email string `json:"email"`
 		Password string `json:"password"`
 		ExpiresInSeconds int64 `json:"expires_in_seconds"`
+		// UseCookies asks for a browser session: the tokens are set as
+		// HttpOnly cookies rather than returned.
+		UseCookies bool `json:"use_cookies"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&u)
 
@@ -79,28 +137,116 @@ func (cfg *ApiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.Database.GetUserByEmail(r.Context(), u.Email)
+	keys := loginKeys(r, u.Email)
+	var user database.User
+	var lockedUntil time.Time
+	isValid := false
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		// The keys stay locked from the lockout check until a failure is
+		// recorded, so parallel guesses are counted one at a time.
+		if err := lockLoginKeys(r.Context(), q, keys); err != nil {
+			return err
+		}
+		var err error
+		lockedUntil, err = loginLockedUntil(r.Context(), q, keys)
+		if err != nil || !lockedUntil.IsZero() {
+			return err
+		}
+
+		user, err = q.GetUserByEmail(r.Context(), u.Email)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		userId := uuid.NullUUID{}
+		if err == nil {
+			userId = uuid.NullUUID{UUID: user.ID, Valid: true}
+			isValid, err = cfg.Passwords.Check(u.Password, user.HashedPassword)
+			isValid = isValid && err == nil
+		} else {
+			cfg.Passwords.DummyCheck(u.Password)
+		}
+		if !isValid {
+			return recordLoginFailure(r.Context(), q, r, keys, userId)
+		}
+		// Failures are kept until the second factor is also right.
+		if user.TotpEnabledAt.Valid {
+			return nil
+		}
+		return clearLoginFailures(r.Context(), q, user.Email)
+	})
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
-
-	isValid, err := auth.CheckPasswordHash(u.Password, user.HashedPassword); if err != nil || !isValid {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`Incorrect email or password`))
+	if !lockedUntil.IsZero() {
+		writeLoginLocked(w, lockedUntil)
+		return
+	}
+	if !isValid {
+		writeLoginFailed(w)
 		return
 	}
 
+	if cfg.Passwords.NeedsRehash(user.HashedPassword) {
+		cfg.rehashPassword(r.Context(), user, u.Password)
+	}
 	user.HashedPassword = ""
 
-	expiresIn := int64(3600) // 24 hours in seconds
-    if u.ExpiresInSeconds > 0 {
-        expiresIn = u.ExpiresInSeconds
-    }
-	tokenString, err := auth.MakeJWT(user.ID, cfg.JWTSecret, time.Duration(expiresIn))
+	expiresIn := accessTokenSeconds(u.ExpiresInSeconds)
+	if user.TotpEnabledAt.Valid {
+		cfg.startMFAChallenge(w, r, user, expiresIn, u.UseCookies)
+		return
+	}
+	cfg.completeLogin(w, r, user, expiresIn, u.UseCookies)
+}
+
+// checkNewPassword applies the password policy to a password being set for
+// the account with the given email, writing the error response itself when
+// the password is rejected.
+func (cfg *ApiConfig) checkNewPassword(w http.ResponseWriter, newPassword, email string) bool {
+	err := cfg.PasswordPolicy.Check(newPassword, email)
+	if password.IsViolation(err) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+		return false
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Password Policy"` + err.Error()))
+		return false
+	}
+	return true
+}
+
+// rehashPassword upgrades a user's password hash to the current parameters
+// while the password is at hand. Failing only leaves the old hash in place,
+// so it is logged rather than failing the login. The old hash is matched so
+// that a password changed in the meantime isn't overwritten.
+func (cfg *ApiConfig) rehashPassword(ctx context.Context, user database.User, plaintext string) {
+	hash, err := cfg.Passwords.Hash(plaintext)
+	if err == nil {
+		_, err = cfg.Database.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+			NewHash: hash,
+			ID:      user.ID,
+			OldHash: user.HashedPassword,
+		})
+	}
+	if err != nil {
+		log.Printf("password: rehash for user %s: %v", user.ID, err)
+	}
+}
+
+// completeLogin issues the tokens for a user who has passed every login
+// step, in the response body or, for a browser session, as cookies.
+func (cfg *ApiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, expiresIn int64, useCookies bool) {
+	// Each login starts a new session, which is a refresh token family;
+	// rotations stay inside it.
+	sessionId := uuid.New()
+	tokenString, err := cfg.Keys.MakeJWT(user.ID, sessionId, time.Duration(expiresIn))
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -108,41 +254,47 @@ func (cfg *ApiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	refreshToken, err := issueRefreshToken(r.Context(), cfg.Database, r, user.ID, sessionId, "", "")
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`"error": "Something went wrong -- Refresh Token"` + err.Error()))
+		w.Write([]byte(`"error": "Something went wrong -- Store Refresh Token"` + err.Error()))
 		return
 	}
 
-	_, err = cfg.Database.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		UserID: user.ID,
-		Token: refreshToken,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour), // Refresh token valid for 7 days
-	})
-
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`"error": "Something went wrong -- Store Refresh Token"` + err.Error()))
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
 
 	type loginResponse struct {
 		Id uuid.UUID       `json:"id"`
 		Is_chirpy_red bool `json:"is_chirpy_red"`
-		Token string        `json:"token"`
-		RefreshToken string `json:"refresh_token"`
+		Token string        `json:"token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		CSRFToken string    `json:"csrf_token,omitempty"`
 		Email string	   `json:"email"`
 	}
 	respUser := loginResponse{
 		Id: user.ID,
-		Is_chirpy_red: user.IsChirpyRed,
+		Is_chirpy_red: isChirpyRed,
 		Token: tokenString,
 		RefreshToken: refreshToken,
 		Email: user.Email,
 	}
+	if useCookies {
+		respUser.CSRFToken, err = setSessionCookies(w, tokenString, expiresIn, refreshToken)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- CSRF Token"` + err.Error()))
+			return
+		}
+		respUser.Token, respUser.RefreshToken = "", ""
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -153,6 +305,36 @@ func (cfg *ApiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// refreshTokenTTL is how long an unused refresh token stays valid.
+const refreshTokenTTL = 60 * 24 * time.Hour
+
+// issueRefreshToken creates a refresh token in the given family, recording
+// the client it was issued to. parent is the token it replaces, or empty for
+// the first token of a login; name is the session's user-chosen name.
+func issueRefreshToken(ctx context.Context, q *database.Queries, r *http.Request, userId, familyId uuid.UUID, parent, name string) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:      userId,
+		Token:       refreshToken,
+		ExpiresAt:   time.Now().UTC().Add(refreshTokenTTL),
+		FamilyID:    familyId,
+		ParentToken: sql.NullString{String: parent, Valid: len(parent) > 0},
+		UserAgent:   truncate(r.UserAgent(), 512),
+		IpAddress:   clientIP(r),
+		Name:        name,
+	})
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Every refresh token can be used once: presenting one that
+// was already rotated means it leaked, so the whole family is revoked.
 func (cfg *ApiConfig) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "application/json")
@@ -160,8 +342,13 @@ func (cfg *ApiConfig) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`"error": "Something went wrong"`))
 		return
 	}
-	token, err := auth.GetBearerToken(r.Header)
-
+	token, fromCookie, err := auth.RequestToken(r, auth.RefreshTokenCookie)
+	if errors.Is(err, auth.ErrInvalidCSRF) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Missing or invalid %s header"}`, auth.CSRFHeader)))
+		return
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
@@ -174,16 +361,51 @@ func (cfg *ApiConfig) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`"error": "Something went wrong -- No Token"`))
 		return
 	}
-	fmt.Sprintf("Refreshing token: %s\n", token)
-	rt, err := cfg.Database.GetRefreshToken(r.Context(), token)
-	if err != nil {
+
+	var userId, sessionId uuid.UUID
+	var newRefreshToken string
+	reused := false
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		// The row lock makes concurrent refreshes with the same token
+		// queue up, so only the first one can rotate it.
+		rt, err := q.LockRefreshToken(r.Context(), token)
+		if err != nil {
+			return err
+		}
+		if rt.RotatedAt.Valid {
+			reused = true
+			revoked, err := q.RevokeRefreshTokenFamily(r.Context(), rt.FamilyID)
+			if err != nil {
+				return err
+			}
+			detail := fmt.Sprintf("family %s replayed; %d tokens revoked", rt.FamilyID, revoked)
+			return recordSecurityEvent(r.Context(), q, r, uuid.NullUUID{UUID: rt.UserID, Valid: true}, securityEventRefreshTokenReuse, detail)
+		}
+		if rt.RevokedAt.Valid || !rt.ExpiresAt.After(time.Now().UTC()) {
+			return sql.ErrNoRows
+		}
+
+		if err := q.MarkRefreshTokenRotated(r.Context(), token); err != nil {
+			return err
+		}
+		userId, sessionId = rt.UserID, rt.FamilyID
+		newRefreshToken, err = issueRefreshToken(r.Context(), q, r, rt.UserID, rt.FamilyID, rt.Token, rt.Name)
+		return err
+	})
+	if reused || errors.Is(err, sql.ErrNoRows) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid refresh token"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
 
-	newToken, err := auth.MakeJWT(rt.UserID, cfg.JWTSecret, time.Duration(3600))
+	newToken, err := cfg.Keys.MakeJWT(userId, sessionId, time.Duration(maxAccessTokenSeconds))
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -192,10 +414,24 @@ func (cfg *ApiConfig) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type refreshResponse struct {
-		Token string `json:"token"`
+		Token string `json:"token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		CSRFToken string `json:"csrf_token,omitempty"`
 	}
 	resp := refreshResponse{
 		Token: newToken,
+		RefreshToken: newRefreshToken,
+	}
+	// A browser session gets its new tokens the way it sent the old one.
+	if fromCookie {
+		resp = refreshResponse{}
+		resp.CSRFToken, err = setSessionCookies(w, newToken, maxAccessTokenSeconds, newRefreshToken)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`"error": "Something went wrong -- CSRF Token"` + err.Error()))
+			return
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -214,8 +450,13 @@ func (cfg *ApiConfig) RevokeToken(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`"error": "Something went wrong"`))
 		return
 	}
-	token, err := auth.GetBearerToken(r.Header)
-	
+	token, fromCookie, err := auth.RequestToken(r, auth.RefreshTokenCookie)
+	if errors.Is(err, auth.ErrInvalidCSRF) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Missing or invalid %s header"}`, auth.CSRFHeader)))
+		return
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
@@ -236,6 +477,9 @@ func (cfg *ApiConfig) RevokeToken(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
+	if fromCookie {
+		clearSessionCookies(w)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNoContent)
@@ -249,38 +493,116 @@ func (cfg *ApiConfig) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`{"error": "Missing or invalid bearer token"}`))
-		return
-	}
-	
-	userId, err := auth.ParseJWT(tokenString, cfg.JWTSecret)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid token: %v"}`, err)))
+	p, ok := cfg.authenticate(w, r, auth.ScopeProfileWrite)
+	if !ok {
 		return
 	}
+	userId := p.UserID
 
+	// Profile fields are pointers so that leaving one out keeps it, while
+	// an empty string clears it.
 	var u struct {
 		Email string `json:"email"`
 		Password string `json:"password"`
+		Handle *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio *string `json:"bio"`
+		AvatarID *string `json:"avatar_id"`
 	}
-	err = json.NewDecoder(r.Body).Decode(&u)
+	err := json.NewDecoder(r.Body).Decode(&u)
 
-	if err != nil || (len(u.Email) == 0 && len(u.Password) == 0) {
+	updatesAccount := len(u.Email) > 0 || len(u.Password) > 0
+	updatesProfile := u.Handle != nil || u.DisplayName != nil || u.Bio != nil || u.AvatarID != nil
+	if err != nil || (!updatesAccount && !updatesProfile) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(`"error": "Something went wrong -- email or password"`))
 		return
 	}
+	// API tokens and OAuth clients can edit the profile but never the
+	// login credentials.
+	if updatesAccount && p.delegated() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "Only a login session can change the email or password"}`))
+		return
+	}
+
+	profileParams := database.UpdateUserProfileParams{ID: userId}
+	if u.Handle != nil {
+		if err := profile.ValidateHandle(*u.Handle); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+			return
+		}
+		profileParams.Handle = sql.NullString{String: *u.Handle, Valid: true}
+	}
+	if u.DisplayName != nil {
+		displayName, err := profile.CleanDisplayName(*u.DisplayName)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+			return
+		}
+		profileParams.DisplayName = sql.NullString{String: displayName, Valid: true}
+	}
+	if u.Bio != nil {
+		bio, err := profile.CleanBio(*u.Bio)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf(`{"error": "%v"}`, err)))
+			return
+		}
+		profileParams.Bio = sql.NullString{String: bio, Valid: true}
+	}
+	if u.AvatarID != nil {
+		profileParams.SetAvatar = true
+		if len(*u.AvatarID) > 0 {
+			avatarId, err := uuid.Parse(*u.AvatarID)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "Invalid avatar_id"}`))
+				return
+			}
+			// Only the user's own uploads can become their avatar.
+			avatar, err := cfg.Database.GetAttachment(r.Context(), avatarId)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+				return
+			}
+			if err != nil || avatar.UserID != userId {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "Unknown avatar_id"}`))
+				return
+			}
+			profileParams.AvatarAttachmentID = uuid.NullUUID{UUID: avatarId, Valid: true}
+		}
+	}
 
 	var hashedPassword string
 	if len(u.Password) > 0 {
-		hashedPassword, err = auth.HashPassword(u.Password)
+		email := u.Email
+		if len(email) == 0 {
+			current, err := cfg.Database.GetUserByID(r.Context(), userId)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+				return
+			}
+			email = current.Email
+		}
+		if !cfg.checkNewPassword(w, u.Password, email) {
+			return
+		}
+		hashedPassword, err = cfg.Passwords.Hash(u.Password)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -295,17 +617,64 @@ func (cfg *ApiConfig) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		Column3: hashedPassword,
 	}
 
-	user, err := cfg.Database.UpdateUser(r.Context(), updateUserParams)
+	var user database.User
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		if updatesAccount {
+			user, err = q.UpdateUser(r.Context(), updateUserParams)
+			if err != nil {
+				return err
+			}
+		}
+		// A new password signs out every other session and revokes every
+		// API token and OAuth grant.
+		if len(hashedPassword) > 0 {
+			_, err = q.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+				UserID:       userId,
+				KeepFamilyID: uuid.NullUUID{UUID: p.SessionID, Valid: p.SessionID != uuid.Nil},
+			})
+			if err != nil {
+				return err
+			}
+			if err := revokeDelegatedAccess(r.Context(), q, userId); err != nil {
+				return err
+			}
+		}
+		if updatesProfile {
+			user, err = q.UpdateUserProfile(r.Context(), profileParams)
+		}
+		return err
+	})
+	if isHandleTaken(err) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Handle is already taken"}`))
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	// Changing the email address clears its verification.
+	if len(u.Email) > 0 && !user.EmailVerifiedAt.Valid {
+		if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+			log.Printf("mail: verification for user %s: %v", user.ID, err)
+		}
+	}
+
+	resp, err := cfg.newUserResponse(r.Context(), user)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
-	user.HashedPassword = "" // Clear hashed password before sending response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(user); err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
 		return
 	}
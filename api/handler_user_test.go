@@ -0,0 +1,72 @@
+package apiConfig
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/throttle"
+)
+
+func TestPasswordChangeRevokesAPITokens(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "gopher")
+	token, err := auth.MakeAPIToken()
+	if err != nil {
+		t.Fatalf("MakeAPIToken() error = %v", err)
+	}
+	_, err = cfg.Database.CreateAPIToken(context.Background(), database.CreateAPITokenParams{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Name:        "script",
+		TokenHash:   auth.HashAPIToken(token),
+		TokenPrefix: token[:8],
+		Scopes:      []string{auth.ScopeChirpsRead},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+
+	rec := serve(t, cfg, user.ID, "PUT /api/users", cfg.UpdateUser, http.MethodPut, "/api/users", `{"password": "a much better passphrase"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateUser() status = %d, body %s", rec.Code, rec.Body)
+	}
+	_, err = cfg.Database.GetActiveAPIToken(context.Background(), auth.HashAPIToken(token))
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetActiveAPIToken() after password change error = %v, want no rows", err)
+	}
+}
+
+func TestLoginLockoutUnderConcurrency(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "guessed")
+	body := fmt.Sprintf(`{"email": %q, "password": "not it"}`, user.Email)
+
+	codes := make(chan int, throttle.AccountPolicy.FreeAttempts+5)
+	for range cap(codes) {
+		go func() {
+			rec := serve(t, cfg, uuid.Nil, "POST /api/login", cfg.LoginUser, http.MethodPost, "/api/login", body)
+			codes <- rec.Code
+		}()
+	}
+	failed := 0
+	for range cap(codes) {
+		switch code := <-codes; code {
+		case http.StatusUnauthorized:
+			failed++
+		case http.StatusTooManyRequests:
+		default:
+			t.Errorf("LoginUser() status = %d, want 401 or 429", code)
+		}
+	}
+	// The attempt that sets off the lockout is still answered normally.
+	if want := throttle.AccountPolicy.FreeAttempts + 1; failed != want {
+		t.Errorf("LoginUser() checked %d passwords, want %d", failed, want)
+	}
+}
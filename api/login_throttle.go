@@ -0,0 +1,193 @@
+package apiConfig
+
+import (
+	"net/http"
+	"encoding/json"
+	"fmt"
+	"time"
+	"context"
+	"strconv"
+	"crypto/subtle"
+	"database/sql"
+	"sort"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/throttle"
+)
+
+// loginKey is one thing failed logins are counted against.
+type loginKey struct {
+	key    string
+	policy throttle.Policy
+}
+
+// loginKeys are the throttle keys for a login attempt on email.
+func loginKeys(r *http.Request, email string) []loginKey {
+	return []loginKey{
+		{key: throttle.AccountKey(email), policy: throttle.AccountPolicy},
+		{key: throttle.IPKey(clientIP(r)), policy: throttle.IPPolicy},
+	}
+}
+
+// lockLoginKeys holds every key until q's transaction ends, so parallel
+// attempts can't all pass the lockout check before one of them records its
+// failure. Keys are taken in order so that two attempts can't deadlock.
+func lockLoginKeys(ctx context.Context, q *database.Queries, keys []loginKey) error {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.key)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := q.LockLoginKey(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loginLockedUntil returns when the longest active lockout on keys ends, or
+// the zero time if none of them is locked.
+func loginLockedUntil(ctx context.Context, q *database.Queries, keys []loginKey) (time.Time, error) {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.key)
+	}
+	lockouts, err := q.ListLoginLockouts(ctx, names)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var until time.Time
+	for _, lockout := range lockouts {
+		if lockout.LockedUntil.After(until) {
+			until = lockout.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// recordLoginFailure counts a failed attempt against every key and locks
+// the ones whose policy says so. userId, if known, is only used to file the
+// security event.
+func recordLoginFailure(ctx context.Context, q *database.Queries, r *http.Request, keys []loginKey, userId uuid.NullUUID) error {
+	now := time.Now().UTC()
+	for _, k := range keys {
+		failures, err := q.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+			Key:         k.key,
+			ResetBefore: now.Add(-k.policy.ResetAfter),
+		})
+		if err != nil {
+			return err
+		}
+		lockout := k.policy.Lockout(int(failures))
+		if lockout == 0 {
+			continue
+		}
+		err = q.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+			LockedUntil: sql.NullTime{Time: now.Add(lockout), Valid: true},
+			Key:         k.key,
+		})
+		if err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("%s locked for %v after %d failures", k.key, lockout, failures)
+		if err := recordSecurityEvent(ctx, q, r, userId, securityEventLoginLockout, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the failures on an account after it logs in.
+// The client address keeps its count, so one working login can't be used
+// to reset a password-spraying run.
+func clearLoginFailures(ctx context.Context, q *database.Queries, email string) error {
+	_, err := q.ClearLoginThrottles(ctx, []string{throttle.AccountKey(email)})
+	return err
+}
+
+func writeLoginLocked(w http.ResponseWriter, until time.Time) {
+	retryAfter := int(time.Until(until).Seconds()) + 1
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"error": "Too many failed login attempts, try again later"}`))
+}
+
+// writeLoginFailed is the only response a wrong email, a wrong password and
+// an unknown account get, so they can't be told apart.
+func writeLoginFailed(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(`{"error": "Incorrect email or password"}`))
+}
+
+// isAdmin checks the request's API key against the admin key. Admin
+// endpoints are disabled when no admin key is configured.
+func (cfg *ApiConfig) isAdmin(r *http.Request) bool {
+	key, err := auth.GetApiKey(r.Header)
+	if err != nil || len(cfg.AdminKey) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(cfg.AdminKey)) == 1
+}
+
+// ClearLoginLockout lets an admin lift the lockout on an email address, a
+// client address, or both.
+func (cfg *ApiConfig) ClearLoginLockout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	if !cfg.isAdmin(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid API key"}`))
+		return
+	}
+
+	var c struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil || (len(c.Email) == 0 && len(c.IP) == 0) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "email or ip is required"}`))
+		return
+	}
+
+	var keys []string
+	if len(c.Email) > 0 {
+		keys = append(keys, throttle.AccountKey(c.Email))
+	}
+	if len(c.IP) > 0 {
+		keys = append(keys, throttle.IPKey(c.IP))
+	}
+
+	var cleared int64
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		cleared, err = q.ClearLoginThrottles(r.Context(), keys)
+		if err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("cleared %v", keys)
+		return recordSecurityEvent(r.Context(), q, r, uuid.NullUUID{}, securityEventLockoutCleared, detail)
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf(`{"cleared": %d}`, cleared)))
+}
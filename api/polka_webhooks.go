@@ -3,18 +3,159 @@ package apiConfig
 import (
 	"net/http"
 	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+	"context"
+	"errors"
 	"github.com/google/uuid"
 	"database/sql"
-	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/database"
+	"github.com/samuelhamann/chirpy/internal/pagination"
+	"github.com/samuelhamann/chirpy/internal/webhook"
+	"github.com/samuelhamann/chirpy/internal/subscription"
+)
+
+const (
+	webhookSourcePolka = "polka"
+	// polkaSignatureHeader carries Polka's HMAC of the body, keyed with
+	// the Polka key. See the webhook package for the format.
+	polkaSignatureHeader = "Polka-Signature"
+	maxWebhookBodyBytes  = 64 << 10
+
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+// Polka's subscription events. An event that leaves the subscription as it
+// was, such as cancelling one that has already ended, is recorded as
+// ignored.
+const (
+	polkaEventUpgraded      = "user.upgraded"
+	polkaEventDowngraded    = "user.downgraded"
+	polkaEventRenewed       = "subscription.renewed"
+	polkaEventCanceled      = "subscription.canceled"
+	polkaEventPaymentFailed = "subscription.payment_failed"
+
+	maxPlanLength = 64
+)
+
+// Reasons an event can never be applied. They are recorded with the event
+// rather than rolled back, since a retry would fail the same way.
+var (
+	errWebhookInvalidUser = errors.New("invalid user ID")
+	errWebhookUnknownUser = errors.New("user not found")
+	errWebhookInvalidData = errors.New("invalid event data")
 )
 
 type PolkaWebhookPayload struct {
+	// ID is unique to the event and the same on every delivery of it.
+	ID    string `json:"id"`
 	Event string `json:"event"`
-	Data  struct {
+	// OccurredAt is when the change happened at Polka, used to put events
+	// that arrive out of order back in order. Events without it are
+	// ordered by when their delivery was signed.
+	OccurredAt *time.Time `json:"occurred_at"`
+	Data       struct {
 		UserId string `json:"user_id"`
+		// Plan is optional; a subscription keeps its plan when it is empty.
+		Plan string `json:"plan"`
+		// ExpiresAt is when the period paid for ends. Upgrades without it
+		// never lapse; renewals must have it.
+		ExpiresAt *time.Time `json:"expires_at"`
 	} `json:"data"`
 }
 
+// applyPolkaEvent makes the change an event asks for and returns the status
+// to record for it. signedAt is when Polka signed the delivery.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, payload PolkaWebhookPayload, signedAt time.Time) (string, error) {
+	switch payload.Event {
+	case polkaEventUpgraded, polkaEventDowngraded, polkaEventRenewed, polkaEventCanceled, polkaEventPaymentFailed:
+	default:
+		return webhookStatusIgnored, nil
+	}
+
+	userID, err := uuid.Parse(payload.Data.UserId)
+	if err != nil {
+		return "", errWebhookInvalidUser
+	}
+	if len(payload.Data.Plan) > maxPlanLength {
+		return "", errWebhookInvalidData
+	}
+	var expiresAt sql.NullTime
+	if payload.Data.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: payload.Data.ExpiresAt.UTC(), Valid: true}
+	}
+	if payload.Event == polkaEventRenewed && !expiresAt.Valid {
+		return "", errWebhookInvalidData
+	}
+	occurredAt := signedAt.UTC()
+	if payload.OccurredAt != nil {
+		occurredAt = payload.OccurredAt.UTC()
+	}
+
+	_, err = q.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errWebhookUnknownUser
+	}
+	if err != nil {
+		return "", err
+	}
+	// Locking the user keeps two events for them from each working from
+	// the state before the other. The row can't be locked alone, since
+	// there is none before their first event.
+	if err := q.LockUserSubscription(ctx, userID); err != nil {
+		return "", err
+	}
+	sub, err := q.LockSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		sub = database.Subscription{UserID: userID}
+	} else if err != nil {
+		return "", err
+	}
+
+	if subscription.Stale(sub, occurredAt) {
+		return webhookStatusIgnored, nil
+	}
+
+	now := time.Now().UTC()
+	changed := true
+	switch payload.Event {
+	case polkaEventUpgraded, polkaEventRenewed:
+		sub = subscription.Activate(sub, payload.Data.Plan, expiresAt, now)
+	case polkaEventDowngraded:
+		sub, changed = subscription.End(sub, now)
+	case polkaEventCanceled:
+		sub, changed = subscription.Cancel(sub, now)
+	case polkaEventPaymentFailed:
+		sub, changed = subscription.PaymentFailed(sub, now)
+	}
+	if !changed {
+		return webhookStatusIgnored, nil
+	}
+	sub.LastEventAt = sql.NullTime{Time: occurredAt, Valid: true}
+
+	_, err = q.SaveSubscription(ctx, database.SaveSubscriptionParams{
+		UserID:      sub.UserID,
+		Plan:        sub.Plan,
+		Status:      sub.Status,
+		StartedAt:   sub.StartedAt,
+		ExpiresAt:   sub.ExpiresAt,
+		CanceledAt:  sub.CanceledAt,
+		EndedAt:     sub.EndedAt,
+		LastEventAt: sub.LastEventAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return webhookStatusProcessed, nil
+}
+
+// HandlePolkaWebhook applies a signed event from Polka exactly once. Polka
+// redelivers anything that doesn't get a 2xx, so a database failure rolls
+// the whole event back to be retried, while an event ID that has been seen
+// before is acknowledged without doing anything.
 func (cfg *ApiConfig) HandlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "application/json")
@@ -23,50 +164,162 @@ func (cfg *ApiConfig) HandlePolkaWebhook(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	polkaKey, err := auth.GetApiKey(r.Header)
-	if err != nil || polkaKey != cfg.PolkaKey {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"error": "Invalid payload"`))
+		return
+	}
+
+	// The signature covers the exact bytes sent, so it is checked before
+	// the body is parsed.
+	signedAt, err := webhook.Verify([]byte(cfg.PolkaKey), r.Header.Get(polkaSignatureHeader), body, time.Now(), webhook.DefaultTolerance)
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`"error": "Invalid API key"`))
+		w.Write([]byte(`{"error": "Invalid webhook signature"}`))
 		return
 	}
 
 	var payload PolkaWebhookPayload
-	err = json.NewDecoder(r.Body).Decode(&payload)
-	if err != nil {
+	err = json.Unmarshal(body, &payload)
+	if err != nil || len(payload.ID) == 0 || len(payload.Event) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(`"error": "Invalid payload"`))
 		return
 	}
 
-	if payload.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	// Every event recorded is acknowledged, including one that can't be
+	// applied and is stored as failed: a redelivery would only be turned
+	// away as a duplicate, and admins find it among the failed events.
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		event, err := q.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+			ID:        uuid.New(),
+			Source:    webhookSourcePolka,
+			EventID:   payload.ID,
+			EventType: payload.Event,
+			Payload:   body,
+		})
+		// A duplicate was already recorded the first time round.
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		status, err := applyPolkaEvent(r.Context(), q, payload, signedAt)
+		detail := ""
+		if errors.Is(err, errWebhookInvalidUser) || errors.Is(err, errWebhookUnknownUser) || errors.Is(err, errWebhookInvalidData) {
+			status, detail = webhookStatusFailed, err.Error()
+		} else if err != nil {
+			return err
+		}
+		return q.FinishWebhookEvent(r.Context(), database.FinishWebhookEventParams{
+			Status: status,
+			Detail: detail,
+			ID:     event.ID,
+		})
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`"error": "Failed to update user"`))
 		return
 	}
 
-	userID, err := uuid.Parse(payload.Data.UserId)
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+type webhookEventResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	Source      string          `json:"source"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Detail      string          `json:"detail,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+// GetWebhookEvents shows admins the webhook events received, newest first,
+// optionally only those with the given status.
+func (cfg *ApiConfig) GetWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`"error": "Something went wrong"`))
+		return
+	}
+
+	if !cfg.isAdmin(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid API key"}`))
+		return
+	}
+
+	page, err := pagination.ParsePage(r.URL.Query())
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`"error": "Invalid user ID"`))
+		w.Write([]byte(fmt.Sprintf(`{"error": "Invalid pagination: %v"}`, err)))
 		return
 	}
-	
-	_, err = cfg.Database.UpgradeUserToChirpyRed(r.Context(), userID)
+	cursorCreatedAt, cursorID := page.CursorParams()
+	status := r.URL.Query().Get("status")
+
+	events, err := cfg.Database.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:          sql.NullString{String: status, Valid: len(status) > 0},
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       int32(page.Limit + 1),
+	})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(`"error": "User not found"`))
-			return
-		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`"error": "Failed to update user"`))
+		w.Write([]byte(`"error": "Something went wrong -- Database"` + err.Error()))
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-	return
-}
\ No newline at end of file
+	nextCursor := ""
+	if len(events) > page.Limit {
+		events = events[:page.Limit]
+		last := events[len(events)-1]
+		nextCursor = pagination.Cursor{CreatedAt: last.ReceivedAt, ID: last.ID}.Encode()
+	}
+
+	type eventListResponse struct {
+		Events     []webhookEventResponse `json:"events"`
+		NextCursor string                 `json:"next_cursor,omitempty"`
+	}
+	resp := eventListResponse{Events: make([]webhookEventResponse, 0, len(events)), NextCursor: nextCursor}
+	for _, event := range events {
+		item := webhookEventResponse{
+			ID:         event.ID,
+			Source:     event.Source,
+			EventID:    event.EventID,
+			EventType:  event.EventType,
+			Payload:    event.Payload,
+			Status:     event.Status,
+			Detail:     event.Detail,
+			ReceivedAt: event.ReceivedAt,
+		}
+		if event.ProcessedAt.Valid {
+			item.ProcessedAt = &event.ProcessedAt.Time
+		}
+		resp.Events = append(resp.Events, item)
+	}
+
+	pagination.SetLinkHeader(w, r, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		w.Write([]byte(`{"error": "Something went wrong -- Encode"}`))
+		return
+	}
+}
@@ -0,0 +1,54 @@
+package apiConfig
+
+import (
+	"net/http"
+	"context"
+	"log"
+	"net"
+	"unicode/utf8"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+const (
+	securityEventRefreshTokenReuse = "refresh_token_reuse"
+	securityEventMFAFailed         = "mfa_failed"
+	securityEventPasswordReset     = "password_reset"
+	securityEventLoginLockout      = "login_lockout"
+	securityEventLockoutCleared    = "lockout_cleared"
+	securityEventPasskeyCloned     = "passkey_cloned"
+)
+
+// clientIP is the address the request came from. X-Forwarded-For is not
+// trusted because nothing guarantees a proxy in front of us set it.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordSecurityEvent stores an event in the audit table and mirrors it to
+// the server log so it shows up in alerting even if the insert fails.
+func recordSecurityEvent(ctx context.Context, q *database.Queries, r *http.Request, userId uuid.NullUUID, eventType, detail string) error {
+	ip := clientIP(r)
+	log.Printf("security: %s user=%s ip=%s %s", eventType, userId.UUID, ip, detail)
+	return q.CreateSecurityEvent(ctx, database.CreateSecurityEventParams{
+		UserID:    userId,
+		EventType: eventType,
+		Detail:    detail,
+		IpAddress: ip,
+	})
+}
+
+// truncate shortens s to at most max bytes without splitting a character.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
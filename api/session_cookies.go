@@ -0,0 +1,89 @@
+package apiConfig
+
+import (
+	"net/http"
+	"errors"
+	"fmt"
+	"time"
+	"github.com/samuelhamann/chirpy/internal/auth"
+)
+
+// refreshCookiePath limits the refresh token cookie to the endpoints that
+// take it, so it isn't sent with every API call.
+const refreshCookiePath = "/api"
+
+// setSessionCookies gives a browser its tokens as HttpOnly cookies instead
+// of in the response body. It returns the new CSRF token, which is also in a
+// cookie the app's scripts can read.
+func setSessionCookies(w http.ResponseWriter, accessToken string, accessExpiresIn int64, refreshToken string) (string, error) {
+	csrfToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	refreshMaxAge := int(refreshTokenTTL / time.Second)
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.AccessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		MaxAge:   int(accessExpiresIn),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.RefreshTokenCookie,
+		Value:    refreshToken,
+		Path:     refreshCookiePath,
+		MaxAge:   refreshMaxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.CSRFCookie,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   refreshMaxAge,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return csrfToken, nil
+}
+
+// clearSessionCookies logs a browser out.
+func clearSessionCookies(w http.ResponseWriter) {
+	for name, path := range map[string]string{
+		auth.AccessTokenCookie:  "/",
+		auth.RefreshTokenCookie: refreshCookiePath,
+		auth.CSRFCookie:         "/",
+	} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     path,
+			MaxAge:   -1,
+			HttpOnly: name != auth.CSRFCookie,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+// requestAccessToken returns the caller's access token from the
+// Authorization header or the session cookie, writing the error response
+// itself when there is none or the CSRF check fails.
+func requestAccessToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tokenString, _, err := auth.RequestToken(r, auth.AccessTokenCookie)
+	if errors.Is(err, auth.ErrInvalidCSRF) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Missing or invalid %s header"}`, auth.CSRFHeader)))
+		return "", false
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Missing or invalid bearer token"}`))
+		return "", false
+	}
+	return tokenString, true
+}
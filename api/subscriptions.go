@@ -0,0 +1,24 @@
+package apiConfig
+
+import (
+	"context"
+	"time"
+	"errors"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/subscription"
+)
+
+// isChirpyRed reports whether the user's subscription is running right now.
+// It is worked out on every call rather than read from a flag, so it is
+// right even before the sweeper has caught up with an expiry.
+func (cfg *ApiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	sub, err := cfg.Database.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return subscription.Entitled(sub, time.Now().UTC()), nil
+}
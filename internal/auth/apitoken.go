@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// APITokenPrefix starts every personal API token, so they can be told apart
+// from JWTs in the Authorization header and found by secret scanners.
+const APITokenPrefix = "chirpy_pat_"
+
+// Scopes an API token can be granted. Logins through /api/login have every
+// scope; account settings such as the password, sessions, MFA and API tokens
+// themselves are never reachable with an API token.
+const (
+	ScopeChirpsRead         = "chirps:read"
+	ScopeChirpsWrite        = "chirps:write"
+	ScopeProfileWrite       = "profile:write"
+	ScopeRelationshipsRead  = "relationships:read"
+	ScopeRelationshipsWrite = "relationships:write"
+)
+
+var Scopes = []string{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeProfileWrite,
+	ScopeRelationshipsRead,
+	ScopeRelationshipsWrite,
+}
+
+var ErrUnknownScope = errors.New("unknown scope")
+
+// MakeAPIToken returns a new random API token.
+func MakeAPIToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + hex.EncodeToString(tokenBytes), nil
+}
+
+// IsAPIToken reports whether a bearer token is an API token rather than a
+// JWT.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashAPIToken is how API tokens are stored. Tokens are long and random, so
+// a fast hash is enough.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeScopes checks that every scope is known and returns them sorted
+// without duplicates.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+		normalized = append(normalized, scope)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
@@ -1,64 +1,52 @@
 package auth
 
 import (
-	"github.com/alexedwards/argon2id"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"time"
 	"errors"
 	"net/http"
 	"strings"
-	"fmt"
 	"crypto/rand"
 	"encoding/hex"
 )
 
-func HashPassword(password string) (string, error){
-	return argon2id.CreateHash(password, argon2id.DefaultParams)
+// Claims are the parts of an access token the API acts on.
+type Claims struct {
+	UserID uuid.UUID
+	// SessionID is the refresh token family the access token was issued
+	// from, or uuid.Nil for tokens that aren't tied to a session.
+	SessionID uuid.UUID
+	// ClientID is the OAuth client the token was issued to, or uuid.Nil
+	// for a first-party login. Client tokens are limited to Scopes and
+	// their SessionID is the OAuth grant.
+	ClientID  uuid.UUID
+	Scopes    []string
+	ExpiresAt time.Time
 }
 
-func CheckPasswordHash(password, hash string) (bool, error) {
-	return argon2id.ComparePasswordAndHash(password, hash)
+type sessionClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
 }
 
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Second * time.Duration(expiresIn))),
-		Subject:   userID.String(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(tokenSecret))
+	return MakeSessionJWT(userID, uuid.Nil, tokenSecret, expiresIn)
 }
 
-func ParseJWT(tokenStr string, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(
-        tokenStr,
-        &jwt.RegisteredClaims{},
-        func(token *jwt.Token) (interface{}, error) {
-            if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-                return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-            }
-            return []byte(tokenSecret), nil
-        },
-    )
-    
-    if err != nil {
-        return uuid.UUID{}, fmt.Errorf("invalid token: %v", err)
-    }
-
-    claims, ok := token.Claims.(*jwt.RegisteredClaims)
-    if !ok || !token.Valid {
-        return uuid.UUID{}, fmt.Errorf("invalid token claims")
-    }
+// MakeSessionJWT is MakeJWT with a session ID recorded in the sid claim.
+func MakeSessionJWT(userID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return NewHMACKeyring(tokenSecret).MakeJWT(userID, sessionID, expiresIn)
+}
 
-    userID, err := uuid.Parse(claims.Subject)
-    if err != nil {
-        return uuid.UUID{}, fmt.Errorf("invalid user ID in token")
-    }
+func ParseJWT(tokenStr string, tokenSecret string) (uuid.UUID, error) {
+	return NewHMACKeyring(tokenSecret).ParseJWT(tokenStr)
+}
 
-    return userID, nil
+func ParseJWTClaims(tokenStr string, tokenSecret string) (Claims, error) {
+	return NewHMACKeyring(tokenSecret).ParseJWTClaims(tokenStr)
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"testing"
+	"slices"
+	"github.com/alexedwards/argon2id"
 	"github.com/google/uuid"
 	"time"
 )
@@ -91,3 +93,87 @@ func TestMakeAndParseJWT(t *testing.T) {
 }
 
 	
+
+func TestSessionJWTClaims(t *testing.T) {
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	sessionID := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+
+	tests := []struct {
+		name      string
+		sessionID uuid.UUID
+	}{
+		{name: "With session", sessionID: sessionID},
+		{name: "Without session", sessionID: uuid.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := MakeSessionJWT(userID, tt.sessionID, "mysecretkey", 3600)
+			if err != nil {
+				t.Fatalf("MakeSessionJWT() error = %v", err)
+			}
+			claims, err := ParseJWTClaims(token, "mysecretkey")
+			if err != nil {
+				t.Fatalf("ParseJWTClaims() error = %v", err)
+			}
+			if claims.UserID != userID || claims.SessionID != tt.sessionID {
+				t.Errorf("ParseJWTClaims() got = %+v, want user %v session %v", claims, userID, tt.sessionID)
+			}
+			if _, err := ParseJWTClaims(token, "othersecret"); err == nil {
+				t.Errorf("ParseJWTClaims() with wrong secret succeeded")
+			}
+		})
+	}
+}
+
+func TestDummyCheckPasswordHash(t *testing.T) {
+	// The dummy hash must be a real argon2id hash, or the comparison
+	// would fail fast and give unknown emails away by timing.
+	if _, _, _, err := argon2id.DecodeHash(defaultPasswordHasher.dummyHash()); err != nil {
+		t.Fatalf("dummyHash() is not a valid hash: %v", err)
+	}
+	DummyCheckPasswordHash("anything")
+}
+
+func TestAPIToken(t *testing.T) {
+	token, err := MakeAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIToken(token) {
+		t.Errorf("IsAPIToken(%q) = false", token)
+	}
+	jwt, err := MakeJWT(uuid.New(), "mysecretkey", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsAPIToken(jwt) {
+		t.Errorf("IsAPIToken() = true for a JWT")
+	}
+	if HashAPIToken(token) == HashAPIToken(token+"x") || len(HashAPIToken(token)) != 64 {
+		t.Errorf("HashAPIToken() = %q", HashAPIToken(token))
+	}
+}
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Sorted and deduplicated", scopes: []string{ScopeChirpsWrite, ScopeChirpsRead, ScopeChirpsWrite}, want: []string{ScopeChirpsRead, ScopeChirpsWrite}},
+		{name: "Empty", scopes: nil, want: []string{}},
+		{name: "Unknown scope", scopes: []string{ScopeChirpsRead, "admin"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("NormalizeScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// Browser sessions keep the access and refresh tokens in HttpOnly cookies
+// instead of JavaScript-readable storage. Cookies are sent by the browser
+// whoever starts the request, so state-changing requests that rely on them
+// must also echo the CSRF cookie in the CSRF header (double-submit). Only
+// same-origin scripts can read the cookie to do that.
+const (
+	AccessTokenCookie  = "chirpy_access_token"
+	RefreshTokenCookie = "chirpy_refresh_token"
+	CSRFCookie         = "chirpy_csrf_token"
+	CSRFHeader         = "X-CSRF-Token"
+)
+
+var (
+	ErrNoToken     = errors.New("authorization header or session cookie missing")
+	ErrInvalidCSRF = errors.New("missing or invalid CSRF token")
+)
+
+// RequestToken returns the bearer token from the Authorization header or,
+// when there is none, from the named session cookie. A cookie token is only
+// returned if the request passes CheckCSRF. fromCookie reports where the
+// token came from.
+func RequestToken(r *http.Request, cookieName string) (token string, fromCookie bool, err error) {
+	if r.Header.Get("Authorization") != "" {
+		token, err := GetBearerToken(r.Header)
+		return token, false, err
+	}
+
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || cookie.Value == "" {
+		return "", false, ErrNoToken
+	}
+	if err := CheckCSRF(r); err != nil {
+		return "", true, err
+	}
+	return cookie.Value, true, nil
+}
+
+// CheckCSRF checks that a state-changing request carries the CSRF cookie's
+// value in the CSRF header. Safe methods always pass.
+func CheckCSRF(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return ErrInvalidCSRF
+	}
+	header := r.Header.Get(CSRFHeader)
+	if subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrInvalidCSRF
+	}
+	return nil
+}
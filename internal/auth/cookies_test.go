@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		header         string
+		cookies        map[string]string
+		csrfHeader     string
+		wantToken      string
+		wantFromCookie bool
+		wantErr        error
+	}{
+		{
+			name:      "Authorization header",
+			method:    http.MethodPost,
+			header:    "Bearer header-token",
+			cookies:   map[string]string{AccessTokenCookie: "cookie-token"},
+			wantToken: "header-token",
+		},
+		{
+			name:           "Cookie on a safe method",
+			method:         http.MethodGet,
+			cookies:        map[string]string{AccessTokenCookie: "cookie-token"},
+			wantToken:      "cookie-token",
+			wantFromCookie: true,
+		},
+		{
+			name:           "Cookie with matching CSRF header",
+			method:         http.MethodPost,
+			cookies:        map[string]string{AccessTokenCookie: "cookie-token", CSRFCookie: "csrf"},
+			csrfHeader:     "csrf",
+			wantToken:      "cookie-token",
+			wantFromCookie: true,
+		},
+		{
+			name:           "Cookie without CSRF header",
+			method:         http.MethodDelete,
+			cookies:        map[string]string{AccessTokenCookie: "cookie-token", CSRFCookie: "csrf"},
+			wantFromCookie: true,
+			wantErr:        ErrInvalidCSRF,
+		},
+		{
+			name:           "Cookie with wrong CSRF header",
+			method:         http.MethodPut,
+			cookies:        map[string]string{AccessTokenCookie: "cookie-token", CSRFCookie: "csrf"},
+			csrfHeader:     "other",
+			wantFromCookie: true,
+			wantErr:        ErrInvalidCSRF,
+		},
+		{
+			name:           "Cookie without CSRF cookie",
+			method:         http.MethodPost,
+			cookies:        map[string]string{AccessTokenCookie: "cookie-token"},
+			csrfHeader:     "",
+			wantFromCookie: true,
+			wantErr:        ErrInvalidCSRF,
+		},
+		{
+			name:    "Nothing",
+			method:  http.MethodGet,
+			wantErr: ErrNoToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/chirps", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.csrfHeader != "" {
+				r.Header.Set(CSRFHeader, tt.csrfHeader)
+			}
+			for name, value := range tt.cookies {
+				r.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+
+			token, fromCookie, err := RequestToken(r, AccessTokenCookie)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RequestToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if token != tt.wantToken || fromCookie != tt.wantFromCookie {
+				t.Errorf("RequestToken() = %q, %v, want %q, %v", token, fromCookie, tt.wantToken, tt.wantFromCookie)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// minRSABits is the smallest RSA modulus LoadKeyring accepts.
+const minRSABits = 2048
+
+// A Keyring signs access tokens with a single key and verifies them against
+// every key it holds. Keeping the previous key in the ring while a new one
+// takes over lets tokens it signed stay valid until they expire.
+type Keyring struct {
+	signingKID string
+	keys       map[string]ringKey
+	kids       []string
+}
+
+type ringKey struct {
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// JWK is a public key in JSON Web Key form. Only the fields used by RSA and
+// Ed25519 keys are included.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served from /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKeyring returns a keyring that signs and verifies HS256 tokens with
+// a shared secret. Its tokens carry no kid and it publishes no keys.
+func NewHMACKeyring(secret string) *Keyring {
+	return &Keyring{
+		keys: map[string]ringKey{
+			"": {method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)},
+		},
+	}
+}
+
+// LoadKeyring reads every <kid>.pem file in dir. Private keys (PKCS#8, or
+// PKCS#1 for RSA) can sign and verify; public keys (PKIX) only verify, which
+// is how a retired key is kept around during rotation. RSA keys sign RS256
+// and Ed25519 keys sign EdDSA.
+//
+// signingKID picks the key new tokens are signed with. It may be empty when
+// dir holds exactly one private key.
+func LoadKeyring(dir, signingKID string) (*Keyring, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := &Keyring{keys: map[string]ringKey{}}
+	var privateKIDs []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+		kid := strings.TrimSuffix(entry.Name(), ".pem")
+		if kid == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseRingKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		ring.keys[kid] = key
+		ring.kids = append(ring.kids, kid)
+		if key.private != nil {
+			privateKIDs = append(privateKIDs, kid)
+		}
+	}
+	sort.Strings(ring.kids)
+
+	if signingKID == "" {
+		if len(privateKIDs) != 1 {
+			return nil, fmt.Errorf("found %d private keys in %s; choose the signing key by ID", len(privateKIDs), dir)
+		}
+		signingKID = privateKIDs[0]
+	}
+	key, ok := ring.keys[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found in %s", signingKID, dir)
+	}
+	if key.private == nil {
+		return nil, fmt.Errorf("signing key %q is a public key", signingKID)
+	}
+	ring.signingKID = signingKID
+	return ring, nil
+}
+
+func parseRingKey(data []byte) (ringKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return ringKey{}, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return ringKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return ringKey{}, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSABits {
+			return ringKey{}, fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+		return ringKey{method: jwt.SigningMethodRS256, private: key, public: &key.PublicKey}, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return ringKey{}, fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+		return ringKey{method: jwt.SigningMethodRS256, public: key}, nil
+	case ed25519.PrivateKey:
+		return ringKey{method: jwt.SigningMethodEdDSA, private: key, public: key.Public()}, nil
+	case ed25519.PublicKey:
+		return ringKey{method: jwt.SigningMethodEdDSA, public: key}, nil
+	default:
+		return ringKey{}, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// SigningKeyID is the kid of the key new tokens are signed with.
+func (k *Keyring) SigningKeyID() string {
+	return k.signingKID
+}
+
+// MakeJWT signs an access token for userID, recording sessionID in the sid
+// claim unless it is uuid.Nil.
+func (k *Keyring) MakeJWT(userID, sessionID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := newSessionClaims(userID, expiresIn)
+	if sessionID != uuid.Nil {
+		claims.SessionID = sessionID.String()
+	}
+	return k.sign(claims)
+}
+
+// MakeClientJWT signs an access token that an OAuth client uses on userID's
+// behalf. It only carries the scopes of the grant it was issued from.
+func (k *Keyring) MakeClientJWT(userID, grantID, clientID uuid.UUID, scopes []string, expiresIn time.Duration) (string, error) {
+	claims := newSessionClaims(userID, expiresIn)
+	claims.SessionID = grantID.String()
+	claims.ClientID = clientID.String()
+	claims.Scope = strings.Join(scopes, " ")
+	return k.sign(claims)
+}
+
+func newSessionClaims(userID uuid.UUID, expiresIn time.Duration) *sessionClaims {
+	return &sessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Second * time.Duration(expiresIn))),
+			Subject:   userID.String(),
+		},
+	}
+}
+
+func (k *Keyring) sign(claims *sessionClaims) (string, error) {
+	key := k.keys[k.signingKID]
+	token := jwt.NewWithClaims(key.method, claims)
+	if k.signingKID != "" {
+		token.Header["kid"] = k.signingKID
+	}
+	return token.SignedString(key.private)
+}
+
+func (k *Keyring) ParseJWT(tokenStr string) (uuid.UUID, error) {
+	claims, err := k.ParseJWTClaims(tokenStr)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return claims.UserID, nil
+}
+
+func (k *Keyring) ParseJWTClaims(tokenStr string) (Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &sessionClaims{}, k.verificationKey)
+	if err != nil {
+		return Claims{}, fmt.Errorf("invalid token: %v", err)
+	}
+
+	claims, ok := token.Claims.(*sessionClaims)
+	if !ok || !token.Valid {
+		return Claims{}, fmt.Errorf("invalid token claims")
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return Claims{}, fmt.Errorf("invalid user ID in token")
+	}
+
+	var sessionID uuid.UUID
+	if claims.SessionID != "" {
+		sessionID, err = uuid.Parse(claims.SessionID)
+		if err != nil {
+			return Claims{}, fmt.Errorf("invalid session ID in token")
+		}
+	}
+
+	result := Claims{UserID: userID, SessionID: sessionID}
+	if claims.ExpiresAt != nil {
+		result.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if claims.ClientID != "" {
+		result.ClientID, err = uuid.Parse(claims.ClientID)
+		if err != nil || sessionID == uuid.Nil {
+			return Claims{}, fmt.Errorf("invalid client ID in token")
+		}
+		result.Scopes = strings.Fields(claims.Scope)
+	}
+	return result, nil
+}
+
+// verificationKey picks the key named by the token's kid header. The token's
+// alg must match the key's, so a public key can never be used as an HMAC
+// secret.
+func (k *Keyring) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public half of every asymmetric key in the ring, sorted
+// by kid.
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, kid := range k.kids {
+		key := k.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func writeKey(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writePrivateKey(t *testing.T, dir, kid string, key interface{}) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, kid, "PRIVATE KEY", der)
+}
+
+func writePublicKey(t *testing.T, dir, kid string, key interface{}) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, kid, "PUBLIC KEY", der)
+}
+
+func TestKeyringSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		wantAlg string
+	}{
+		{name: "RS256", key: rsaKey, wantAlg: "RS256"},
+		{name: "EdDSA", key: edKey, wantAlg: "EdDSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePrivateKey(t, dir, "key-1", tt.key)
+			ring, err := LoadKeyring(dir, "")
+			if err != nil {
+				t.Fatalf("LoadKeyring() error = %v", err)
+			}
+
+			userID, sessionID := uuid.New(), uuid.New()
+			token, err := ring.MakeJWT(userID, sessionID, 3600)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &sessionClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != "key-1" || parsed.Header["alg"] != tt.wantAlg {
+				t.Errorf("header = %v, want kid key-1 alg %s", parsed.Header, tt.wantAlg)
+			}
+
+			claims, err := ring.ParseJWTClaims(token)
+			if err != nil {
+				t.Fatalf("ParseJWTClaims() error = %v", err)
+			}
+			if claims.UserID != userID || claims.SessionID != sessionID {
+				t.Errorf("ParseJWTClaims() got = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestKeyringClientJWT(t *testing.T) {
+	ring := NewHMACKeyring("mysecretkey")
+	userID, grantID, clientID := uuid.New(), uuid.New(), uuid.New()
+	token, err := ring.MakeClientJWT(userID, grantID, clientID, []string{ScopeChirpsRead, ScopeChirpsWrite}, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ring.ParseJWTClaims(token)
+	if err != nil {
+		t.Fatalf("ParseJWTClaims() error = %v", err)
+	}
+	if claims.UserID != userID || claims.SessionID != grantID || claims.ClientID != clientID {
+		t.Errorf("ParseJWTClaims() got = %+v", claims)
+	}
+	if !slices.Equal(claims.Scopes, []string{ScopeChirpsRead, ScopeChirpsWrite}) {
+		t.Errorf("ParseJWTClaims() scopes = %q", claims.Scopes)
+	}
+	if claims.ExpiresAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("ParseJWTClaims() expires at %v", claims.ExpiresAt)
+	}
+
+	// A first-party login has no client and no scope limit.
+	token, err = ring.MakeJWT(userID, grantID, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = ring.ParseJWTClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ClientID != uuid.Nil || claims.Scopes != nil {
+		t.Errorf("ParseJWTClaims() got = %+v for a login token", claims)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir := t.TempDir()
+	writePrivateKey(t, oldDir, "old", oldKey)
+	oldRing, err := LoadKeyring(oldDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := oldRing.MakeJWT(uuid.New(), uuid.Nil, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After rotation the old key is kept as a public key only.
+	dir := t.TempDir()
+	writePublicKey(t, dir, "old", &oldKey.PublicKey)
+	writePrivateKey(t, dir, "new", newKey)
+	ring, err := LoadKeyring(dir, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.ParseJWT(oldToken); err != nil {
+		t.Errorf("ParseJWT() of a token signed by the retired key: %v", err)
+	}
+	newToken, err := ring.MakeJWT(uuid.New(), uuid.Nil, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oldRing.ParseJWT(newToken); err == nil {
+		t.Errorf("ParseJWT() accepted a token signed by a key it doesn't hold")
+	}
+
+	if _, err := LoadKeyring(dir, "old"); err == nil {
+		t.Errorf("LoadKeyring() accepted a public key for signing")
+	}
+	writePrivateKey(t, dir, "old", oldKey)
+	if _, err := LoadKeyring(dir, ""); err == nil {
+		t.Errorf("LoadKeyring() picked a signing key out of two")
+	}
+}
+
+func TestKeyringRejectsForgedTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "key-1", rsaKey)
+	ring, err := LoadKeyring(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &sessionClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()}}
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		key    interface{}
+	}{
+		// HS256 keyed with the public key, the classic algorithm confusion.
+		{name: "HS256 with public key", method: jwt.SigningMethodHS256, kid: "key-1", key: publicDER},
+		{name: "Unknown kid", method: jwt.SigningMethodRS256, kid: "key-2", key: rsaKey},
+		{name: "Missing kid", method: jwt.SigningMethodRS256, key: rsaKey},
+		{name: "HS256 without kid", method: jwt.SigningMethodHS256, key: []byte("mysecretkey")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(tt.method, claims)
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+			signed, err := token.SignedString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ring.ParseJWT(signed); err == nil {
+				t.Errorf("ParseJWT() accepted a forged token")
+			}
+		})
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "b-ed", edKey)
+	writePublicKey(t, dir, "a-rsa", &rsaKey.PublicKey)
+	ring, err := LoadKeyring(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := ring.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() got %d keys, want 2", len(set.Keys))
+	}
+
+	rsaJWK := set.Keys[0]
+	if rsaJWK.Kid != "a-rsa" || rsaJWK.Kty != "RSA" || rsaJWK.Alg != "RS256" || rsaJWK.Use != "sig" {
+		t.Errorf("JWKS() RSA key = %+v", rsaJWK)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	e, _ := base64.RawURLEncoding.DecodeString(rsaJWK.E)
+	if new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(rsaKey.E) {
+		t.Errorf("JWKS() RSA key doesn't match the public key")
+	}
+
+	edJWK := set.Keys[1]
+	if edJWK.Kid != "b-ed" || edJWK.Kty != "OKP" || edJWK.Crv != "Ed25519" || edJWK.Alg != "EdDSA" {
+		t.Errorf("JWKS() Ed25519 key = %+v", edJWK)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(edJWK.X)
+	if !edPublic.Equal(ed25519.PublicKey(x)) {
+		t.Errorf("JWKS() Ed25519 key doesn't match the public key")
+	}
+
+	if keys := NewHMACKeyring("mysecretkey").JWKS().Keys; len(keys) != 0 {
+		t.Errorf("HMAC keyring published %d keys", len(keys))
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"github.com/alexedwards/argon2id"
+	"sync"
+)
+
+// DefaultPasswordParams are the argon2id parameters passwords have always
+// been hashed with. Configured parameters start from these.
+var DefaultPasswordParams = *argon2id.DefaultParams
+
+// PasswordHasher hashes passwords with argon2id. Changing its parameters
+// only affects new hashes; NeedsRehash finds the old ones so they can be
+// upgraded when their password is next available, at login.
+type PasswordHasher struct {
+	params    argon2id.Params
+	dummyHash func() string
+}
+
+func NewPasswordHasher(params argon2id.Params) *PasswordHasher {
+	h := &PasswordHasher{params: params}
+	// dummyHash is a real hash of a throwaway password, made with the same
+	// parameters as Hash.
+	h.dummyHash = sync.OnceValue(func() string {
+		hash, _ := h.Hash("chirpy-dummy-password")
+		return hash
+	})
+	return h
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	return argon2id.CreateHash(password, &h.params)
+}
+
+// Check compares password with a hash made with any parameters.
+func (h *PasswordHasher) Check(password, hash string) (bool, error) {
+	return argon2id.ComparePasswordAndHash(password, hash)
+}
+
+// DummyCheck does the work of Check without an account, so a login for an
+// unknown email takes as long as a wrong password for a real one.
+func (h *PasswordHasher) DummyCheck(password string) {
+	argon2id.ComparePasswordAndHash(password, h.dummyHash())
+}
+
+// NeedsRehash reports whether hash was made with cheaper parameters than
+// the hasher's. Parallelism isn't compared: it changes how the work is
+// spread over threads, not how much there is.
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	params, salt, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return false
+	}
+	return params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		params.KeyLength < h.params.KeyLength ||
+		uint32(len(salt)) < h.params.SaltLength
+}
+
+var defaultPasswordHasher = NewPasswordHasher(DefaultPasswordParams)
+
+// HashPassword hashes with DefaultPasswordParams. It is for secrets other
+// than account passwords, such as recovery codes.
+func HashPassword(password string) (string, error) {
+	return defaultPasswordHasher.Hash(password)
+}
+
+func CheckPasswordHash(password, hash string) (bool, error) {
+	return defaultPasswordHasher.Check(password, hash)
+}
+
+func DummyCheckPasswordHash(password string) {
+	defaultPasswordHasher.DummyCheck(password)
+}
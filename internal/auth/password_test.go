@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+	"github.com/alexedwards/argon2id"
+)
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	// Small parameters keep the test fast; only their relative sizes matter.
+	base := argon2id.Params{Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hash, err := NewPasswordHasher(base).Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *argon2id.Params)
+		want   bool
+	}{
+		{name: "Same parameters", modify: func(p *argon2id.Params) {}, want: false},
+		{name: "More memory", modify: func(p *argon2id.Params) { p.Memory = 2048 }, want: true},
+		{name: "More iterations", modify: func(p *argon2id.Params) { p.Iterations = 3 }, want: true},
+		{name: "Longer key", modify: func(p *argon2id.Params) { p.KeyLength = 64 }, want: true},
+		{name: "Longer salt", modify: func(p *argon2id.Params) { p.SaltLength = 32 }, want: true},
+		{name: "Less memory", modify: func(p *argon2id.Params) { p.Memory = 512 }, want: false},
+		{name: "More parallelism", modify: func(p *argon2id.Params) { p.Parallelism = 4 }, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := base
+			tt.modify(&params)
+			h := NewPasswordHasher(params)
+			if got := h.NeedsRehash(hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+			// Old hashes still verify whatever the current parameters.
+			if match, err := h.Check("correct horse battery staple", hash); err != nil || !match {
+				t.Errorf("Check() = %v, %v, want true", match, err)
+			}
+		})
+	}
+
+	if NewPasswordHasher(base).NeedsRehash("not a hash") {
+		t.Error("NeedsRehash() = true for a malformed hash")
+	}
+}
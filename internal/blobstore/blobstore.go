@@ -0,0 +1,49 @@
+// Package blobstore stores uploaded media behind a small interface so the
+// API does not care whether bytes live on local disk or in an S3 bucket.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+type BlobStore interface {
+	// Put stores size bytes read from body under key, replacing any
+	// existing blob.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidateKey accepts slash-separated keys made of letters, digits, '-', '_'
+// and '.', without empty or dot-only segments, so a key can never escape the
+// store's root.
+func ValidateKey(key string) error {
+	if len(key) == 0 || len(key) > 512 {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || strings.Trim(segment, ".") == "" {
+			return ErrInvalidKey
+		}
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			case r == '-', r == '_', r == '.':
+			default:
+				return ErrInvalidKey
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "Simple key", key: "media/abc-123.png"},
+		{name: "Empty key", key: "", wantErr: true},
+		{name: "Parent directory", key: "media/../secret", wantErr: true},
+		{name: "Leading slash", key: "/etc/passwd", wantErr: true},
+		{name: "Double slash", key: "media//abc", wantErr: true},
+		{name: "Backslash", key: `media\abc`, wantErr: true},
+		{name: "Space", key: "media/a b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSignV4 checks the signer against the get-vanilla case from the AWS
+// Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signV4(req, emptyPayloadHash, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+// fakeS3 is an in-memory stand-in for an S3 bucket that rejects requests
+// whose signature does not match the shared credentials.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	signed, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for name, values := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-") {
+			signed.Header[name] = values
+		}
+	}
+	date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	signV4(signed, r.Header.Get("X-Amz-Content-Sha256"), "test-key", "test-secret", "us-east-1", "s3", date)
+	if signed.Header.Get("Authorization") != r.Header.Get("Authorization") {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "SignatureDoesNotMatch")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestStores(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	local, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	stores := []struct {
+		name  string
+		store BlobStore
+	}{
+		{name: "Local", store: local},
+		{name: "S3", store: &S3Store{
+			Endpoint:        server.URL,
+			Bucket:          "chirpy",
+			Region:          "us-east-1",
+			AccessKeyID:     "test-key",
+			SecretAccessKey: "test-secret",
+			Client:          server.Client(),
+		}},
+	}
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			data := []byte("not really a png")
+
+			if err := tt.store.Put(ctx, "media/one.png", bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			body, err := tt.store.Get(ctx, "media/one.png")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			got, _ := io.ReadAll(body)
+			body.Close()
+			if !bytes.Equal(got, data) {
+				t.Errorf("Get = %q, want %q", got, data)
+			}
+
+			if err := tt.store.Delete(ctx, "media/one.png"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := tt.store.Get(ctx, "media/one.png"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+			}
+			if err := tt.store.Delete(ctx, "media/one.png"); err != nil {
+				t.Errorf("Delete of missing blob: %v", err)
+			}
+			if err := tt.store.Put(ctx, "../escape", bytes.NewReader(data), int64(len(data)), "image/png"); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put with bad key error = %v, want ErrInvalidKey", err)
+			}
+		})
+	}
+}
+
+func TestS3StoreRejectsWrongSecret(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := &S3Store{
+		Endpoint:        server.URL,
+		Bucket:          "chirpy",
+		Region:          "us-east-1",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "wrong-secret",
+		Client:          server.Client(),
+	}
+	err := store.Put(context.Background(), "media/one.png", strings.NewReader("x"), 1, "image/png")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put with wrong secret error = %v, want a 403", err)
+	}
+}
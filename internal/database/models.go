@@ -6,33 +6,257 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID          uuid.UUID    `json:"id"`
+	UserID      uuid.UUID    `json:"user_id"`
+	Name        string       `json:"name"`
+	TokenHash   string       `json:"token_hash"`
+	TokenPrefix string       `json:"token_prefix"`
+	Scopes      []string     `json:"scopes"`
+	CreatedAt   time.Time    `json:"created_at"`
+	LastUsedAt  sql.NullTime `json:"last_used_at"`
+	ExpiresAt   sql.NullTime `json:"expires_at"`
+	RevokedAt   sql.NullTime `json:"revoked_at"`
+}
+
+type Attachment struct {
+	ID          uuid.UUID     `json:"id"`
+	UserID      uuid.UUID     `json:"user_id"`
+	ChirpID     uuid.NullUUID `json:"chirp_id"`
+	Position    int32         `json:"position"`
+	StorageKey  string        `json:"storage_key"`
+	ContentType string        `json:"content_type"`
+	SizeBytes   int64         `json:"size_bytes"`
+	Width       int32         `json:"width"`
+	Height      int32         `json:"height"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Body         string        `json:"body"`
+	UserID       uuid.UUID     `json:"user_id"`
+	InReplyToID  uuid.NullUUID `json:"in_reply_to_id"`
+	DeletedAt    sql.NullTime  `json:"deleted_at"`
+	LikeCount    int32         `json:"like_count"`
+	RechirpCount int32         `json:"rechirp_count"`
+	EditedAt     sql.NullTime  `json:"edited_at"`
+	SearchVector interface{}   `json:"search_vector"`
+}
+
+type ChirpHashtag struct {
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Tag        string    `json:"tag"`
+	StartIndex int32     `json:"start_index"`
+	EndIndex   int32     `json:"end_index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ChirpLike struct {
 	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
-type RefreshToken struct {
-	Token     string       `json:"token"`
+type ChirpMention struct {
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	StartIndex int32     `json:"start_index"`
+	EndIndex   int32     `json:"end_index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type EmailToken struct {
+	TokenHash string       `json:"token_hash"`
 	UserID    uuid.UUID    `json:"user_id"`
+	Purpose   string       `json:"purpose"`
+	Email     string       `json:"email"`
 	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type LoginThrottle struct {
+	Key           string       `json:"key"`
+	Failures      int32        `json:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
+type MfaChallenge struct {
+	Token            string    `json:"token"`
+	UserID           uuid.UUID `json:"user_id"`
+	ExpiresInSeconds int64     `json:"expires_in_seconds"`
+	Attempts         int32     `json:"attempts"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	UseCookies       bool      `json:"use_cookies"`
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
 	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type OauthClient struct {
+	ID           uuid.UUID      `json:"id"`
+	UserID       uuid.UUID      `json:"user_id"`
+	Name         string         `json:"name"`
+	SecretHash   sql.NullString `json:"secret_hash"`
+	RedirectUris []string       `json:"redirect_uris"`
+	Scopes       []string       `json:"scopes"`
+	CreatedAt    time.Time      `json:"created_at"`
+	RevokedAt    sql.NullTime   `json:"revoked_at"`
+}
+
+type OauthCode struct {
+	CodeHash      string        `json:"code_hash"`
+	ClientID      uuid.UUID     `json:"client_id"`
+	UserID        uuid.UUID     `json:"user_id"`
+	RedirectUri   string        `json:"redirect_uri"`
+	Scopes        []string      `json:"scopes"`
+	CodeChallenge string        `json:"code_challenge"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	UsedAt        sql.NullTime  `json:"used_at"`
+	GrantID       uuid.NullUUID `json:"grant_id"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type OauthGrant struct {
+	ID                       uuid.UUID      `json:"id"`
+	ClientID                 uuid.UUID      `json:"client_id"`
+	UserID                   uuid.UUID      `json:"user_id"`
+	Scopes                   []string       `json:"scopes"`
+	RefreshTokenHash         string         `json:"refresh_token_hash"`
+	PreviousRefreshTokenHash sql.NullString `json:"previous_refresh_token_hash"`
+	RefreshExpiresAt         time.Time      `json:"refresh_expires_at"`
+	CreatedAt                time.Time      `json:"created_at"`
+	LastUsedAt               time.Time      `json:"last_used_at"`
+	RevokedAt                sql.NullTime   `json:"revoked_at"`
+}
+
+type Rechirp struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RefreshToken struct {
+	Token       string         `json:"token"`
+	UserID      uuid.UUID      `json:"user_id"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	RevokedAt   sql.NullTime   `json:"revoked_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	FamilyID    uuid.UUID      `json:"family_id"`
+	ParentToken sql.NullString `json:"parent_token"`
+	RotatedAt   sql.NullTime   `json:"rotated_at"`
+	UserAgent   string         `json:"user_agent"`
+	IpAddress   string         `json:"ip_address"`
+	LastUsedAt  time.Time      `json:"last_used_at"`
+	Name        string         `json:"name"`
+}
+
+type ScheduledChirp struct {
+	ID            uuid.UUID     `json:"id"`
+	UserID        uuid.UUID     `json:"user_id"`
+	Body          string        `json:"body"`
+	InReplyToID   uuid.NullUUID `json:"in_reply_to_id"`
+	AttachmentIds []uuid.UUID   `json:"attachment_ids"`
+	PublishAt     time.Time     `json:"publish_at"`
+	Failure       string        `json:"failure"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID     `json:"id"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	EventType string        `json:"event_type"`
+	Detail    string        `json:"detail"`
+	IpAddress string        `json:"ip_address"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type Subscription struct {
+	UserID      uuid.UUID    `json:"user_id"`
+	Plan        string       `json:"plan"`
+	Status      string       `json:"status"`
+	StartedAt   time.Time    `json:"started_at"`
+	ExpiresAt   sql.NullTime `json:"expires_at"`
+	CanceledAt  sql.NullTime `json:"canceled_at"`
+	EndedAt     sql.NullTime `json:"ended_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	LastEventAt sql.NullTime `json:"last_event_at"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID                 uuid.UUID      `json:"id"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	Email              string         `json:"email"`
+	HashedPassword     string         `json:"hashed_password"`
+	Handle             sql.NullString `json:"handle"`
+	DisplayName        string         `json:"display_name"`
+	Bio                string         `json:"bio"`
+	AvatarAttachmentID uuid.NullUUID  `json:"avatar_attachment_id"`
+	TotpSecret         sql.NullString `json:"totp_secret"`
+	TotpEnabledAt      sql.NullTime   `json:"totp_enabled_at"`
+	TotpLastCounter    int64          `json:"totp_last_counter"`
+	EmailVerifiedAt    sql.NullTime   `json:"email_verified_at"`
+}
+
+type WebauthnChallenge struct {
+	Token     string        `json:"token"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	Purpose   string        `json:"purpose"`
+	Challenge []byte        `json:"challenge"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type WebauthnCredential struct {
+	ID         []byte       `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	PublicKey  []byte       `json:"public_key"`
+	SignCount  int64        `json:"sign_count"`
+	Name       string       `json:"name"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Source      string          `json:"source"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Detail      string          `json:"detail"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt sql.NullTime    `json:"processed_at"`
 }
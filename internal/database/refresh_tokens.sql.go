@@ -7,25 +7,40 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (user_id, token, expires_at, created_at, updated_at)
-VALUES ($1, $2, $3, NOW(), NOW())
-RETURNING token, user_id, expires_at, revoked_at, created_at, updated_at
+INSERT INTO refresh_tokens (user_id, token, expires_at, family_id, parent_token, user_agent, ip_address, name, last_used_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW(), NOW())
+RETURNING token, user_id, expires_at, revoked_at, created_at, updated_at, family_id, parent_token, rotated_at, user_agent, ip_address, last_used_at, name
 `
 
 type CreateRefreshTokenParams struct {
-	UserID    uuid.UUID `json:"user_id"`
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Token       string         `json:"token"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	FamilyID    uuid.UUID      `json:"family_id"`
+	ParentToken sql.NullString `json:"parent_token"`
+	UserAgent   string         `json:"user_agent"`
+	IpAddress   string         `json:"ip_address"`
+	Name        string         `json:"name"`
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.UserID, arg.Token, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.UserID,
+		arg.Token,
+		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.ParentToken,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.Name,
+	)
 	var i RefreshToken
 	err := row.Scan(
 		&i.Token,
@@ -34,14 +49,22 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FamilyID,
+		&i.ParentToken,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.Name,
 	)
 	return i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, user_id, expires_at, revoked_at, created_at, updated_at FROM refresh_tokens 
+SELECT token, user_id, expires_at, revoked_at, created_at, updated_at, family_id, parent_token, rotated_at, user_agent, ip_address, last_used_at, name FROM refresh_tokens 
 WHERE token = $1 
   AND revoked_at IS NULL 
+  AND rotated_at IS NULL
   AND expires_at > NOW()
 `
 
@@ -55,15 +78,157 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FamilyID,
+		&i.ParentToken,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.Name,
+	)
+	return i, err
+}
+
+const listSessions = `-- name: ListSessions :many
+SELECT family_id, name, user_agent, ip_address, last_used_at, expires_at,
+    (SELECT MIN(family.created_at) FROM refresh_tokens AS family WHERE family.family_id = refresh_tokens.family_id)::timestamp AS started_at
+FROM refresh_tokens
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND rotated_at IS NULL
+  AND expires_at > NOW()
+ORDER BY last_used_at DESC, family_id
+`
+
+type ListSessionsRow struct {
+	FamilyID   uuid.UUID `json:"family_id"`
+	Name       string    `json:"name"`
+	UserAgent  string    `json:"user_agent"`
+	IpAddress  string    `json:"ip_address"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+func (q *Queries) ListSessions(ctx context.Context, userID uuid.UUID) ([]ListSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSessionsRow
+	for rows.Next() {
+		var i ListSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.Name,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.StartedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockRefreshToken = `-- name: LockRefreshToken :one
+SELECT token, user_id, expires_at, revoked_at, created_at, updated_at, family_id, parent_token, rotated_at, user_agent, ip_address, last_used_at, name FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, lockRefreshToken, token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FamilyID,
+		&i.ParentToken,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.Name,
 	)
 	return i, err
 }
 
+const markRefreshTokenRotated = `-- name: MarkRefreshTokenRotated :exec
+UPDATE refresh_tokens
+SET rotated_at = NOW(), updated_at = NOW()
+WHERE token = $1
+`
+
+func (q *Queries) MarkRefreshTokenRotated(ctx context.Context, token string) error {
+	_, err := q.db.ExecContext(ctx, markRefreshTokenRotated, token)
+	return err
+}
+
+const renameSession = `-- name: RenameSession :execrows
+UPDATE refresh_tokens
+SET name = $1, updated_at = NOW()
+WHERE family_id = $2
+  AND user_id = $3
+  AND revoked_at IS NULL
+  AND rotated_at IS NULL
+  AND expires_at > NOW()
+`
+
+type RenameSessionParams struct {
+	Name     string    `json:"name"`
+	FamilyID uuid.UUID `json:"family_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) RenameSession(ctx context.Context, arg RenameSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, renameSession, arg.Name, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeOtherSessions = `-- name: RevokeOtherSessions :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
+  AND family_id IS DISTINCT FROM $2
+  AND revoked_at IS NULL
+`
+
+type RevokeOtherSessionsParams struct {
+	UserID       uuid.UUID     `json:"user_id"`
+	KeepFamilyID uuid.NullUUID `json:"keep_family_id"`
+}
+
+func (q *Queries) RevokeOtherSessions(ctx context.Context, arg RevokeOtherSessionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeOtherSessions, arg.UserID, arg.KeepFamilyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :one
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1
-RETURNING token, user_id, expires_at, revoked_at, created_at, updated_at
+RETURNING token, user_id, expires_at, revoked_at, created_at, updated_at, family_id, parent_token, rotated_at, user_agent, ip_address, last_used_at, name
 `
 
 func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
@@ -76,6 +241,48 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (Refresh
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FamilyID,
+		&i.ParentToken,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.Name,
 	)
 	return i, err
 }
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1
+  AND user_id = $2
+  AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	FamilyID uuid.UUID `json:"family_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSession, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,85 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	MaxHashtagLength = 64
+	// MaxMentionLength is long enough for a handle or a UUID written as
+	// @<uuid>.
+	MaxMentionLength = 36
+)
+
+// Offsets are rune indexes into the chirp body; End is exclusive and both
+// include the leading # or @.
+type Hashtag struct {
+	Tag   string
+	Start int
+	End   int
+}
+
+type Mention struct {
+	Username string
+	Start    int
+	End      int
+}
+
+type Entities struct {
+	Hashtags []Hashtag
+	Mentions []Mention
+}
+
+// Parse finds #hashtags and @mentions in a chirp body. A marker only counts
+// at the start of the body or after a character that can't be part of a
+// word, so email addresses and things like C# are left alone. Hashtags are
+// lowercased and must contain at least one letter.
+func Parse(body string) Entities {
+	var result Entities
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		marker := runes[i]
+		if marker != '#' && marker != '@' {
+			continue
+		}
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isTokenRune(marker, runes[end]) {
+			end++
+		}
+		if marker == '@' {
+			// Hyphens are allowed inside UUIDs but not at the edge.
+			for end > i+1 && runes[end-1] == '-' {
+				end--
+			}
+		}
+		token := string(runes[i+1 : end])
+		length := end - i - 1
+
+		switch {
+		case marker == '#' && length > 0 && length <= MaxHashtagLength && strings.IndexFunc(token, unicode.IsLetter) >= 0:
+			result.Hashtags = append(result.Hashtags, Hashtag{Tag: strings.ToLower(token), Start: i, End: end})
+		case marker == '@' && length > 0 && length <= MaxMentionLength:
+			result.Mentions = append(result.Mentions, Mention{Username: token, Start: i, End: end})
+		}
+		if end > i+1 {
+			i = end - 1
+		}
+	}
+	return result
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '#' || r == '@'
+}
+
+func isTokenRune(marker, r rune) bool {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+		return marker == '#' || r < unicode.MaxASCII
+	}
+	return marker == '@' && r == '-'
+}
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Entities
+	}{
+		{
+			name: "Hashtags and mentions",
+			body: "Hi @alice, loving #GoLang and #go_1",
+			want: Entities{
+				Hashtags: []Hashtag{{Tag: "golang", Start: 18, End: 25}, {Tag: "go_1", Start: 30, End: 35}},
+				Mentions: []Mention{{Username: "alice", Start: 3, End: 9}},
+			},
+		},
+		{
+			name: "Offsets count runes not bytes",
+			body: "café #crème",
+			want: Entities{
+				Hashtags: []Hashtag{{Tag: "crème", Start: 5, End: 11}},
+			},
+		},
+		{
+			name: "UUID mention keeps inner hyphens",
+			body: "cc @550e8400-e29b-41d4-a716-446655440000-",
+			want: Entities{
+				Mentions: []Mention{{Username: "550e8400-e29b-41d4-a716-446655440000", Start: 3, End: 40}},
+			},
+		},
+		{
+			name: "Emails, numbers and C# are ignored",
+			body: "mail me@example.com about C# issue #42",
+			want: Entities{},
+		},
+		{
+			name: "Bare markers are ignored",
+			body: "# @ ## #@x",
+			want: Entities{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
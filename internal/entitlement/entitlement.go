@@ -0,0 +1,235 @@
+// Package entitlement decides what a user may do according to their tier.
+// Handlers look a user's Entitlements up in a Table instead of hard-coding
+// limits, so the perks of Chirpy Red can be changed in one place.
+package entitlement
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type Tier string
+
+const (
+	TierFree      Tier = "free"
+	TierChirpyRed Tier = "chirpy_red"
+)
+
+// TierFor is the tier of a user who is or isn't Chirpy Red.
+func TierFor(chirpyRed bool) Tier {
+	if chirpyRed {
+		return TierChirpyRed
+	}
+	return TierFree
+}
+
+type Entitlements struct {
+	Tier Tier `json:"tier"`
+	// MaxChirpLength is the longest chirp body allowed, in bytes.
+	MaxChirpLength int `json:"max_chirp_length"`
+	// MaxAttachments is how many media uploads one chirp may carry.
+	MaxAttachments int `json:"max_attachments"`
+	// ChirpsPerHour caps chirps posted or scheduled in any hour. Zero means
+	// no limit.
+	ChirpsPerHour int `json:"chirps_per_hour"`
+	// CanEdit allows editing chirps for EditWindow after posting, or at any
+	// time when EditWindow is zero.
+	CanEdit    bool     `json:"can_edit"`
+	EditWindow Duration `json:"edit_window"`
+	// CanSchedule allows up to MaxScheduled chirps waiting to be posted, at
+	// most MaxScheduleAhead in the future.
+	CanSchedule      bool     `json:"can_schedule"`
+	MaxScheduled     int      `json:"max_scheduled"`
+	MaxScheduleAhead Duration `json:"max_schedule_ahead"`
+}
+
+func (e Entitlements) Validate() error {
+	if e.MaxChirpLength < 1 {
+		return errors.New("max_chirp_length must be at least 1")
+	}
+	if e.MaxAttachments < 0 || e.ChirpsPerHour < 0 || e.EditWindow < 0 {
+		return errors.New("max_attachments, chirps_per_hour and edit_window can't be negative")
+	}
+	if e.CanSchedule && (e.MaxScheduled < 1 || e.MaxScheduleAhead <= 0) {
+		return errors.New("max_scheduled and max_schedule_ahead must be positive when can_schedule is set")
+	}
+	return nil
+}
+
+var (
+	ErrEmptyChirp          = errors.New("chirp is empty")
+	ErrChirpTooLong        = errors.New("chirp is too long")
+	ErrTooManyAttachments  = errors.New("too many attachments")
+	ErrRateLimited         = errors.New("too many chirps in the last hour")
+	ErrEditNotAllowed      = errors.New("editing chirps requires Chirpy Red")
+	ErrScheduleNotAllowed  = errors.New("scheduling chirps requires Chirpy Red")
+	ErrTooManyScheduled    = errors.New("too many scheduled chirps")
+	ErrScheduleInPast      = errors.New("publish_at must be in the future")
+	ErrScheduleTooFarAhead = errors.New("publish_at is too far ahead")
+)
+
+// CheckChirp reports whether a chirp with body and the given number of
+// attachments may be posted.
+func (e Entitlements) CheckChirp(body string, attachments int) error {
+	if len(body) == 0 {
+		return ErrEmptyChirp
+	}
+	if len(body) > e.MaxChirpLength {
+		return fmt.Errorf("%w: the limit is %d", ErrChirpTooLong, e.MaxChirpLength)
+	}
+	if attachments > e.MaxAttachments {
+		return fmt.Errorf("%w: the limit is %d", ErrTooManyAttachments, e.MaxAttachments)
+	}
+	return nil
+}
+
+// CheckRate reports whether another chirp may be posted by a user who has
+// posted or scheduled recent chirps in the last hour.
+func (e Entitlements) CheckRate(recent int) error {
+	if e.ChirpsPerHour > 0 && recent >= e.ChirpsPerHour {
+		return fmt.Errorf("%w: the limit is %d", ErrRateLimited, e.ChirpsPerHour)
+	}
+	return nil
+}
+
+// CheckEdit reports whether chirps may be edited at all; how long after
+// posting is up to EditWindow.
+func (e Entitlements) CheckEdit() error {
+	if !e.CanEdit {
+		return ErrEditNotAllowed
+	}
+	return nil
+}
+
+// CheckSchedule reports whether a chirp may be scheduled for publishAt by a
+// user with pending chirps already waiting.
+func (e Entitlements) CheckSchedule(publishAt, now time.Time, pending int) error {
+	if !e.CanSchedule {
+		return ErrScheduleNotAllowed
+	}
+	if !publishAt.After(now) {
+		return ErrScheduleInPast
+	}
+	if publishAt.Sub(now) > time.Duration(e.MaxScheduleAhead) {
+		return fmt.Errorf("%w: the limit is %s", ErrScheduleTooFarAhead, time.Duration(e.MaxScheduleAhead))
+	}
+	if pending >= e.MaxScheduled {
+		return fmt.Errorf("%w: the limit is %d", ErrTooManyScheduled, e.MaxScheduled)
+	}
+	return nil
+}
+
+// Table holds the Entitlements of each tier.
+type Table map[Tier]Entitlements
+
+// DefaultTable keeps free users to the chirp length and attachment limits
+// Chirpy has always had, but adds a new limit of 30 chirps an hour and
+// leaves editing and scheduling to Chirpy Red, which gets room on every
+// limit.
+func DefaultTable() Table {
+	return Table{
+		TierFree: {
+			Tier:           TierFree,
+			MaxChirpLength: 140,
+			MaxAttachments: 4,
+			ChirpsPerHour:  30,
+		},
+		TierChirpyRed: {
+			Tier:             TierChirpyRed,
+			MaxChirpLength:   1000,
+			MaxAttachments:   10,
+			ChirpsPerHour:    300,
+			CanEdit:          true,
+			CanSchedule:      true,
+			MaxScheduled:     100,
+			MaxScheduleAhead: Duration(90 * 24 * time.Hour),
+		},
+	}
+}
+
+// For returns the Entitlements of tier. Tiers missing from the table get
+// the free tier's.
+func (t Table) For(tier Tier) Entitlements {
+	if e, ok := t[tier]; ok {
+		return e
+	}
+	return t[TierFree]
+}
+
+func (t Table) Validate() error {
+	for _, tier := range []Tier{TierFree, TierChirpyRed} {
+		e, ok := t[tier]
+		if !ok {
+			return fmt.Errorf("%s: missing", tier)
+		}
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", tier, err)
+		}
+	}
+	return nil
+}
+
+// ReadTable overrides base with the JSON object read from r, which maps tier
+// names to the fields being changed, for example
+//
+//	{"chirpy_red": {"max_chirp_length": 500, "edit_window": "1h"}}
+//
+// Fields that aren't given keep their value from base.
+func ReadTable(r io.Reader, base Table) (Table, error) {
+	var raw map[Tier]json.RawMessage
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	table := make(Table, len(base))
+	for tier, e := range base {
+		table[tier] = e
+	}
+	for tier, override := range raw {
+		e, ok := table[tier]
+		if !ok {
+			return nil, fmt.Errorf("unknown tier %q", tier)
+		}
+		fields := json.NewDecoder(bytes.NewReader(override))
+		fields.DisallowUnknownFields()
+		if err := fields.Decode(&e); err != nil {
+			return nil, fmt.Errorf("%s: %w", tier, err)
+		}
+		e.Tier = tier
+		table[tier] = e
+	}
+	if err := table.Validate(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func LoadTable(path string, base Table) (Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTable(f, base)
+}
+
+// Duration is a time.Duration written in JSON as a string such as "15m".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
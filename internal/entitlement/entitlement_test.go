@@ -0,0 +1,178 @@
+package entitlement
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultTableValid(t *testing.T) {
+	if err := DefaultTable().Validate(); err != nil {
+		t.Fatalf("DefaultTable().Validate() error = %v", err)
+	}
+}
+
+func TestFor(t *testing.T) {
+	table := DefaultTable()
+	if got := table.For(TierFor(false)); got.Tier != TierFree {
+		t.Errorf("For(free) got tier %q", got.Tier)
+	}
+	if got := table.For(TierFor(true)); got.Tier != TierChirpyRed {
+		t.Errorf("For(chirpy red) got tier %q", got.Tier)
+	}
+	if got := table.For("platinum"); got.Tier != TierFree {
+		t.Errorf("For(unknown) got tier %q, want free", got.Tier)
+	}
+}
+
+func TestCheckChirp(t *testing.T) {
+	table := DefaultTable()
+	tests := []struct {
+		name        string
+		tier        Tier
+		body        string
+		attachments int
+		wantErr     error
+	}{
+		{name: "Free short", tier: TierFree, body: "hello", attachments: 4},
+		{name: "Free at limit", tier: TierFree, body: strings.Repeat("a", 140)},
+		{name: "Free too long", tier: TierFree, body: strings.Repeat("a", 141), wantErr: ErrChirpTooLong},
+		{name: "Free too many attachments", tier: TierFree, body: "hello", attachments: 5, wantErr: ErrTooManyAttachments},
+		{name: "Free empty", tier: TierFree, body: "", wantErr: ErrEmptyChirp},
+		{name: "Red long", tier: TierChirpyRed, body: strings.Repeat("a", 1000), attachments: 10},
+		{name: "Red too long", tier: TierChirpyRed, body: strings.Repeat("a", 1001), wantErr: ErrChirpTooLong},
+		{name: "Red too many attachments", tier: TierChirpyRed, body: "hello", attachments: 11, wantErr: ErrTooManyAttachments},
+		{name: "Red empty", tier: TierChirpyRed, body: "", wantErr: ErrEmptyChirp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := table.For(tt.tier).CheckChirp(tt.body, tt.attachments)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Errorf("CheckChirp() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckRate(t *testing.T) {
+	table := DefaultTable()
+	tests := []struct {
+		name    string
+		tier    Tier
+		recent  int
+		wantErr error
+	}{
+		{name: "Free under", tier: TierFree, recent: 29},
+		{name: "Free at limit", tier: TierFree, recent: 30, wantErr: ErrRateLimited},
+		{name: "Red past free limit", tier: TierChirpyRed, recent: 30},
+		{name: "Red at limit", tier: TierChirpyRed, recent: 300, wantErr: ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := table.For(tt.tier).CheckRate(tt.recent)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Errorf("CheckRate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	unlimited := Entitlements{MaxChirpLength: 140}
+	if err := unlimited.CheckRate(1 << 20); err != nil {
+		t.Errorf("CheckRate() with no limit error = %v", err)
+	}
+}
+
+func TestCheckEdit(t *testing.T) {
+	table := DefaultTable()
+	if err := table.For(TierFree).CheckEdit(); !errors.Is(err, ErrEditNotAllowed) {
+		t.Errorf("free CheckEdit() error = %v, want %v", err, ErrEditNotAllowed)
+	}
+	if err := table.For(TierChirpyRed).CheckEdit(); err != nil {
+		t.Errorf("chirpy red CheckEdit() error = %v", err)
+	}
+}
+
+func TestCheckSchedule(t *testing.T) {
+	table := DefaultTable()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		tier      Tier
+		publishAt time.Time
+		pending   int
+		wantErr   error
+	}{
+		{name: "Free", tier: TierFree, publishAt: now.Add(time.Hour), wantErr: ErrScheduleNotAllowed},
+		{name: "Red", tier: TierChirpyRed, publishAt: now.Add(time.Hour), pending: 99},
+		{name: "Red furthest ahead", tier: TierChirpyRed, publishAt: now.Add(90 * 24 * time.Hour)},
+		{name: "Red now", tier: TierChirpyRed, publishAt: now, wantErr: ErrScheduleInPast},
+		{name: "Red past", tier: TierChirpyRed, publishAt: now.Add(-time.Minute), wantErr: ErrScheduleInPast},
+		{name: "Red too far", tier: TierChirpyRed, publishAt: now.Add(91 * 24 * time.Hour), wantErr: ErrScheduleTooFarAhead},
+		{name: "Red too many", tier: TierChirpyRed, publishAt: now.Add(time.Hour), pending: 100, wantErr: ErrTooManyScheduled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := table.For(tt.tier).CheckSchedule(tt.publishAt, now, tt.pending)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Errorf("CheckSchedule() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadTable(t *testing.T) {
+	input := `{
+		"free": {"max_chirp_length": 200, "can_edit": true, "edit_window": "5m"},
+		"chirpy_red": {"chirps_per_hour": 0}
+	}`
+	table, err := ReadTable(strings.NewReader(input), DefaultTable())
+	if err != nil {
+		t.Fatalf("ReadTable() error = %v", err)
+	}
+
+	free := table.For(TierFree)
+	if free.MaxChirpLength != 200 || !free.CanEdit || time.Duration(free.EditWindow) != 5*time.Minute || free.MaxAttachments != 4 {
+		t.Errorf("ReadTable() free got = %+v", free)
+	}
+	red := table.For(TierChirpyRed)
+	if red.ChirpsPerHour != 0 || red.MaxChirpLength != 1000 || !red.CanSchedule {
+		t.Errorf("ReadTable() chirpy red got = %+v", red)
+	}
+	if DefaultTable().For(TierFree).MaxChirpLength != 140 {
+		t.Errorf("ReadTable() changed the base table")
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Not JSON", input: `max_chirp_length=200`},
+		{name: "Unknown tier", input: `{"platinum": {"max_chirp_length": 200}}`},
+		{name: "Unknown field", input: `{"free": {"max_chirp_size": 200}}`},
+		{name: "Bad duration", input: `{"chirpy_red": {"edit_window": "soon"}}`},
+		{name: "Zero length", input: `{"free": {"max_chirp_length": 0}}`},
+		{name: "Negative attachments", input: `{"free": {"max_attachments": -1}}`},
+		{name: "Scheduling without room", input: `{"free": {"can_schedule": true}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadTable(strings.NewReader(tt.input), DefaultTable()); err == nil {
+				t.Errorf("ReadTable() expected error")
+			}
+		})
+	}
+}
+
+func TestEntitlementsJSON(t *testing.T) {
+	data, err := json.Marshal(DefaultTable().For(TierChirpyRed))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"max_schedule_ahead":"2160h0m0s"`) || !strings.Contains(string(data), `"tier":"chirpy_red"`) {
+		t.Errorf("Marshal() got = %s", data)
+	}
+}
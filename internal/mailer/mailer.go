@@ -0,0 +1,75 @@
+// Package mailer sends the account emails Chirpy needs, such as password
+// resets and address verification, through a pluggable transport.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Message is a plain text email.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// validate rejects messages that would produce a malformed or injected
+// header, such as a subject containing a newline.
+func (msg Message) validate() error {
+	if _, err := mail.ParseAddress(msg.From); err != nil {
+		return fmt.Errorf("%w: from address: %v", ErrInvalidMessage, err)
+	}
+	addr, err := mail.ParseAddress(msg.To)
+	if err != nil || addr.Address != msg.To {
+		return fmt.Errorf("%w: to address %q", ErrInvalidMessage, msg.To)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject contains a line break", ErrInvalidMessage)
+	}
+	return nil
+}
+
+// Bytes renders msg in RFC 5322 form with CRLF line endings.
+func (msg Message) Bytes(date time.Time) ([]byte, error) {
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", msg.From)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "8bit")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	for _, line := range strings.Split(body, "\n") {
+		// A lone "." ends the DATA section in SMTP.
+		if strings.HasPrefix(line, ".") {
+			line = "." + line
+		}
+		buf.WriteString(line + "\r\n")
+	}
+	return buf.Bytes(), nil
+}
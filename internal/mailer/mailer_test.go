@@ -0,0 +1,183 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageBytes(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		msg      Message
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name: "Plain message",
+			msg:  Message{From: "Chirpy <noreply@chirpy.test>", To: "gopher@example.com", Subject: "Hello", Body: "Line one\nLine two"},
+			contains: []string{
+				"From: Chirpy <noreply@chirpy.test>\r\n",
+				"To: gopher@example.com\r\n",
+				"Subject: Hello\r\n",
+				"Date: Wed, 01 May 2024 12:00:00 +0000\r\n",
+				"\r\n\r\nLine one\r\nLine two\r\n",
+			},
+		},
+		{
+			name:     "Non-ASCII subject is encoded",
+			msg:      Message{From: "noreply@chirpy.test", To: "gopher@example.com", Subject: "Réinitialiser"},
+			contains: []string{"Subject: =?utf-8?q?R=C3=A9initialiser?=\r\n"},
+		},
+		{
+			name:     "Leading dots are stuffed",
+			msg:      Message{From: "noreply@chirpy.test", To: "gopher@example.com", Subject: "Dots", Body: ".\n..x"},
+			contains: []string{"\r\n..\r\n...x\r\n"},
+		},
+		{name: "Header injection in subject", msg: Message{From: "noreply@chirpy.test", To: "gopher@example.com", Subject: "Hi\r\nBcc: victim@example.com"}, wantErr: true},
+		{name: "Extra recipient", msg: Message{From: "noreply@chirpy.test", To: "gopher@example.com, victim@example.com", Subject: "Hi"}, wantErr: true},
+		{name: "Display name in recipient", msg: Message{From: "noreply@chirpy.test", To: "Gopher <gopher@example.com>", Subject: "Hi"}, wantErr: true},
+		{name: "Invalid sender", msg: Message{From: "chirpy", To: "gopher@example.com", Subject: "Hi"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.msg.Bytes(date)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMessage) {
+					t.Fatalf("Bytes() error = %v, want ErrInvalidMessage", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("Bytes() = %q, missing %q", data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := &MemoryMailer{}
+	msg := Message{From: "noreply@chirpy.test", To: "gopher@example.com", Subject: "Hello", Body: "Hi"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send(context.Background(), Message{From: "noreply@chirpy.test", To: "not an address"}); err == nil {
+		t.Errorf("Send() accepted an invalid message")
+	}
+	if sent := m.Sent(); len(sent) != 1 || sent[0] != msg {
+		t.Errorf("Sent() = %+v", sent)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	m, err := NewFileMailer(t.TempDir() + "/mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err := m.Send(context.Background(), Message{From: "noreply@chirpy.test", To: "gopher@example.com", Subject: "Hello", Body: "Hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(m.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("FileMailer wrote %d files, want 2", len(entries))
+	}
+	data, err := os.ReadFile(m.Dir + "/" + entries[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: gopher@example.com\r\n") {
+		t.Errorf("FileMailer wrote %q", data)
+	}
+}
+
+// fakeSMTP accepts a single message and returns what it received.
+func fakeSMTP(t *testing.T) (addr string, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var transcript strings.Builder
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				out <- transcript.String()
+				return
+			default:
+				reply("502 Unknown command")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	m := &SMTPMailer{Addr: addr}
+	err := m.Send(context.Background(), Message{From: "Chirpy <noreply@chirpy.test>", To: "gopher@example.com", Subject: "Hello", Body: "Hi"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	select {
+	case transcript := <-received:
+		for _, want := range []string{"MAIL FROM:<noreply@chirpy.test>", "RCPT TO:<gopher@example.com>", "Subject: Hello\r\n", "\r\nHi\r\n"} {
+			if !strings.Contains(transcript, want) {
+				t.Errorf("transcript missing %q:\n%s", want, transcript)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fake server received nothing")
+	}
+}
@@ -0,0 +1,149 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func encode(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	pngData := encode(t, "png", 64, 32)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Info
+		wantErr error
+	}{
+		{
+			name: "PNG",
+			data: pngData,
+			want: Info{ContentType: "image/png", Extension: ".png", Width: 64, Height: 32},
+		},
+		{
+			name: "JPEG",
+			data: encode(t, "jpeg", 10, 20),
+			want: Info{ContentType: "image/jpeg", Extension: ".jpg", Width: 10, Height: 20},
+		},
+		{
+			name: "GIF",
+			data: encode(t, "gif", 3, 3),
+			want: Info{ContentType: "image/gif", Extension: ".gif", Width: 3, Height: 3},
+		},
+		{
+			name:    "HTML is rejected",
+			data:    []byte("<html><script>alert(1)</script></html>"),
+			wantErr: ErrUnsupportedType,
+		},
+		{
+			name:    "Truncated PNG",
+			data:    pngData[:20],
+			wantErr: ErrInvalidImage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Inspect(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Inspect() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Inspect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedURLs(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1_700_000_000, 0)
+	signed := SignURL(key, "/api/media/abc", "abc", now.Add(time.Hour))
+
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", signed, err)
+	}
+	if parsed.Path != "/api/media/abc" {
+		t.Errorf("path = %q, want /api/media/abc", parsed.Path)
+	}
+	query := parsed.Query()
+
+	tampered := url.Values{"expires": {"9999999999"}, "signature": query["signature"]}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		id      string
+		query   url.Values
+		now     time.Time
+		wantErr error
+	}{
+		{name: "Valid", key: key, id: "abc", query: query, now: now},
+		{name: "Expired", key: key, id: "abc", query: query, now: now.Add(2 * time.Hour), wantErr: ErrExpired},
+		{name: "Other object", key: key, id: "abd", query: query, now: now, wantErr: ErrInvalidSignature},
+		{name: "Other key", key: []byte("other"), id: "abc", query: query, now: now, wantErr: ErrInvalidSignature},
+		{name: "Extended expiry", key: key, id: "abc", query: tampered, now: now, wantErr: ErrInvalidSignature},
+		{name: "Missing parameters", key: key, id: "abc", query: url.Values{}, now: now, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyURL(tt.key, tt.id, tt.query, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeriveSigningKey(t *testing.T) {
+	secret := []byte("jwt-secret")
+	key, err := DeriveSigningKey(secret)
+	if err != nil {
+		t.Fatalf("DeriveSigningKey() error = %v", err)
+	}
+	if len(key) != sha256.Size {
+		t.Errorf("DeriveSigningKey() length = %d, want %d", len(key), sha256.Size)
+	}
+	if bytes.Equal(key, secret) {
+		t.Error("DeriveSigningKey() returned the secret itself")
+	}
+	again, err := DeriveSigningKey(secret)
+	if err != nil || !bytes.Equal(key, again) {
+		t.Errorf("DeriveSigningKey() again = %x, %v, want %x", again, err, key)
+	}
+	other, err := DeriveSigningKey([]byte("other-secret"))
+	if err != nil || bytes.Equal(key, other) {
+		t.Errorf("DeriveSigningKey() for another secret = %x, %v, want a different key", other, err)
+	}
+}
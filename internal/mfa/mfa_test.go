@@ -0,0 +1,122 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors from RFC 6238, appendix B.
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := hotp(key, Counter(time.Unix(tt.unix, 0)), 8); got != tt.want {
+				t.Errorf("hotp() at %d = %s, want %s", tt.unix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "050471" {
+		t.Fatalf("Code() = %s, want 050471", code)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		wantOK bool
+	}{
+		{name: "Current step", secret: secret, code: code, at: now, wantOK: true},
+		{name: "Previous step", secret: secret, code: code, at: now.Add(Period), wantOK: true},
+		{name: "Next step", secret: secret, code: code, at: now.Add(-Period), wantOK: true},
+		{name: "Too old", secret: secret, code: code, at: now.Add(2 * Period), wantOK: false},
+		{name: "Spaces are ignored", secret: secret, code: "050 471", at: now, wantOK: true},
+		{name: "Lower case secret", secret: strings.ToLower(secret), code: code, at: now, wantOK: true},
+		{name: "Wrong code", secret: secret, code: "123456", at: now, wantOK: false},
+		{name: "Short code", secret: secret, code: "05047", at: now, wantOK: false},
+		{name: "Invalid secret", secret: "not base32!", code: code, at: now, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := Validate(tt.secret, tt.code, tt.at)
+			if ok != tt.wantOK {
+				t.Fatalf("Validate() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && counter != Counter(now) {
+				t.Errorf("Validate() counter = %d, want %d", counter, Counter(now))
+			}
+		})
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Fatalf("Code() with a generated secret: %v", err)
+	}
+
+	uri, err := url.Parse(URI("Chirpy", "gopher@example.com", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Chirpy:gopher@example.com" {
+		t.Errorf("URI() = %s", uri)
+	}
+	query := uri.Query()
+	if query.Get("secret") != secret || query.Get("issuer") != "Chirpy" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("URI() query = %v", query)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !LooksLikeRecoveryCode(code) || !LooksLikeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))) {
+			t.Errorf("LooksLikeRecoveryCode(%q) = false", code)
+		}
+		if seen[code] {
+			t.Errorf("GenerateRecoveryCodes() repeated %q", code)
+		}
+		seen[code] = true
+	}
+
+	if NormalizeRecoveryCode("K7FQA2MX NC4HS8PT") != NormalizeRecoveryCode("k7fqa2mx-nc4hs8pt") {
+		t.Errorf("NormalizeRecoveryCode() doesn't ignore case and separators")
+	}
+	if LooksLikeRecoveryCode("123456") {
+		t.Errorf("LooksLikeRecoveryCode() accepted a TOTP code")
+	}
+}
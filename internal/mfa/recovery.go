@@ -0,0 +1,50 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+const (
+	// RecoveryCodeCount is how many recovery codes are issued at a time.
+	RecoveryCodeCount = 10
+
+	recoveryCodeBytes = 10
+)
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes returns RecoveryCodeCount new codes formatted for
+// display, such as "k7fqa2mx-nc4hs8pt". Store them with NormalizeRecoveryCode
+// applied.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := recoveryEncoding.EncodeToString(raw)
+		codes = append(codes, code[:len(code)/2]+"-"+code[len(code)/2:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the formatting a user may or may not type,
+// so "K7FQA2MX NC4HS8PT" and "k7fqa2mx-nc4hs8pt" are the same code.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// LooksLikeRecoveryCode reports whether code could be a recovery code
+// rather than a TOTP code.
+func LooksLikeRecoveryCode(code string) bool {
+	code = NormalizeRecoveryCode(code)
+	if len(code) != recoveryEncoding.EncodedLen(recoveryCodeBytes) {
+		return false
+	}
+	_, err := recoveryEncoding.DecodeString(code)
+	return err == nil
+}
@@ -0,0 +1,120 @@
+// Package oauth holds the protocol rules of Chirpy's OAuth 2.0
+// authorization server: PKCE (RFC 7636), redirect URIs and scope strings.
+// Storage and token issuance live with the handlers.
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ChallengeMethodS256 is the only PKCE method accepted. "plain" would let
+// anyone who sees the authorization request redeem the code.
+const ChallengeMethodS256 = "S256"
+
+// Error codes from RFC 6749, sections 4.1.2.1 and 5.2, and RFC 7009.
+const (
+	ErrInvalidRequest          = "invalid_request"
+	ErrInvalidClient           = "invalid_client"
+	ErrInvalidGrant            = "invalid_grant"
+	ErrUnauthorizedClient      = "unauthorized_client"
+	ErrUnsupportedGrantType    = "unsupported_grant_type"
+	ErrUnsupportedResponseType = "unsupported_response_type"
+	ErrInvalidScope            = "invalid_scope"
+	ErrAccessDenied            = "access_denied"
+)
+
+var ErrInvalidRedirectURI = errors.New("redirect URI must be an absolute https URL, or http on a loopback address, without a fragment")
+
+// validVerifier reports whether s is a well-formed code verifier or S256
+// challenge: 43 to 128 characters from the unreserved set.
+func validVerifier(s string) bool {
+	if len(s) < 43 || len(s) > 128 {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidChallenge reports whether challenge could be the S256 challenge of
+// some verifier, which is always 43 characters of unpadded base64url.
+func ValidChallenge(challenge string) bool {
+	if len(challenge) != 43 {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil
+}
+
+// Challenge is the S256 code challenge for verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE reports whether verifier is the one challenge was made from.
+func VerifyPKCE(verifier, challenge string) bool {
+	if !validVerifier(verifier) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(Challenge(verifier)), []byte(challenge)) == 1
+}
+
+// ValidateRedirectURI checks a redirect URI at client registration. Plain
+// http is only allowed on loopback addresses, for native apps and local
+// development.
+func ValidateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return ErrInvalidRedirectURI
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return ErrInvalidRedirectURI
+}
+
+// ParseScope splits a space-separated scope parameter.
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// FormatScope joins scopes into a scope parameter.
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// RedirectURL adds params to the query of a registered redirect URI, keeping
+// any query it already has.
+func RedirectURL(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,182 @@
+package pagination
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor marks the last row of a page in a (created_at, id) keyset.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	// Scope names the sort order and filters of the listing the cursor
+	// came from, so that it can't be used to page through another one.
+	Scope string
+}
+
+// Encode returns the opaque string handed to clients as next_cursor.
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	if c.Scope != "" {
+		raw += "|" + c.Scope
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) < 2 {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return Cursor{}, errors.New("malformed cursor timestamp")
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return Cursor{}, errors.New("malformed cursor id")
+	}
+	cursor := Cursor{CreatedAt: createdAt, ID: id}
+	if len(parts) == 3 {
+		cursor.Scope = parts[2]
+	}
+	return cursor, nil
+}
+
+// ParseLimit reads a page size, falling back to DefaultLimit when raw is empty.
+func ParseLimit(raw string) (int, error) {
+	if raw == "" {
+		return DefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive integer")
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	return limit, nil
+}
+
+// Page describes the query a handler was asked for, taken from the
+// cursor and limit query parameters.
+type Page struct {
+	Cursor *Cursor
+	Limit  int
+	// Scope is carried by the cursors for the following pages.
+	Scope string
+}
+
+// ParsePage reads a page of a listing that has no sort or filter options.
+func ParsePage(query url.Values) (Page, error) {
+	return ParseScopedPage(query, "")
+}
+
+// ParseScopedPage reads a page of a listing whose sort order and filters
+// are described by scope. A cursor from a listing with a different scope
+// is rejected, since it would silently skip or repeat rows.
+func ParseScopedPage(query url.Values, scope string) (Page, error) {
+	limit, err := ParseLimit(query.Get("limit"))
+	if err != nil {
+		return Page{}, err
+	}
+	page := Page{Limit: limit, Scope: scope}
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := DecodeCursor(raw)
+		if err != nil {
+			return Page{}, err
+		}
+		if cursor.Scope != scope {
+			return Page{}, errors.New("cursor is for a different sort or filter")
+		}
+		page.Cursor = &cursor
+	}
+	return page, nil
+}
+
+// Next returns the cursor for the page after the row at (createdAt, id).
+func (p Page) Next(createdAt time.Time, id uuid.UUID) string {
+	return Cursor{CreatedAt: createdAt, ID: id, Scope: p.Scope}.Encode()
+}
+
+// CursorParams splits the page cursor into the nullable keyset arguments
+// taken by the paginated sqlc queries.
+func (p Page) CursorParams() (sql.NullTime, uuid.NullUUID) {
+	if p.Cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+// OffsetPage is used for results that have no stable keyset, such as
+// search results ordered by rank. Its cursor is just an encoded offset.
+type OffsetPage struct {
+	Offset int
+	Limit  int
+}
+
+func EncodeOffset(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset|" + strconv.Itoa(offset)))
+}
+
+func DecodeOffset(s string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, errors.New("malformed cursor")
+	}
+	value, ok := strings.CutPrefix(string(raw), "offset|")
+	if !ok {
+		return 0, errors.New("malformed cursor")
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, errors.New("malformed cursor offset")
+	}
+	return offset, nil
+}
+
+func ParseOffsetPage(query url.Values) (OffsetPage, error) {
+	limit, err := ParseLimit(query.Get("limit"))
+	if err != nil {
+		return OffsetPage{}, err
+	}
+	page := OffsetPage{Limit: limit}
+	if raw := query.Get("cursor"); raw != "" {
+		page.Offset, err = DecodeOffset(raw)
+		if err != nil {
+			return OffsetPage{}, err
+		}
+	}
+	return page, nil
+}
+
+// SetLinkHeader advertises the next page using an RFC 8288 Link header.
+// It does nothing when there is no next page.
+func SetLinkHeader(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", nextCursor)
+	next.RawQuery = query.Encode()
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
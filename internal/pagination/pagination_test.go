@@ -0,0 +1,147 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{
+			name: "Microsecond timestamp",
+			cursor: Cursor{
+				CreatedAt: time.Date(2024, 5, 1, 12, 30, 45, 123456000, time.UTC),
+				ID:        uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+			},
+		},
+		{
+			name: "Whole second timestamp",
+			cursor: Cursor{
+				CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				ID:        uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+			},
+		},
+		{
+			name: "Scoped",
+			cursor: Cursor{
+				CreatedAt: time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC),
+				ID:        uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				Scope:     "sort=desc,author_id=6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor() error = %v", err)
+			}
+			if !decoded.CreatedAt.Equal(tt.cursor.CreatedAt) || decoded.ID != tt.cursor.ID || decoded.Scope != tt.cursor.Scope {
+				t.Errorf("DecodeCursor() got = %+v, want %+v", decoded, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, raw := range []string{"", "not base64!", "bm8tc2VwYXJhdG9y", "MjAyNHxub3QtYS11dWlk"} {
+		if _, err := DecodeCursor(raw); err == nil {
+			t.Errorf("DecodeCursor(%q) expected error", raw)
+		}
+	}
+}
+
+func TestParseScopedPage(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC)
+	id := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	asc := Page{Scope: "sort=asc"}.Next(createdAt, id)
+
+	tests := []struct {
+		name    string
+		cursor  string
+		scope   string
+		wantErr bool
+	}{
+		{name: "No cursor", scope: "sort=desc"},
+		{name: "Same scope", cursor: asc, scope: "sort=asc"},
+		{name: "Other sort", cursor: asc, scope: "sort=desc", wantErr: true},
+		{name: "Added filter", cursor: asc, scope: "sort=asc,author_id=" + id.String(), wantErr: true},
+		{name: "Scoped cursor on unscoped listing", cursor: asc, scope: "", wantErr: true},
+		{name: "Unscoped cursor on scoped listing", cursor: Cursor{CreatedAt: createdAt, ID: id}.Encode(), scope: "sort=asc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{}
+			if tt.cursor != "" {
+				query.Set("cursor", tt.cursor)
+			}
+			page, err := ParseScopedPage(query, tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScopedPage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && page.Scope != tt.scope {
+				t.Errorf("ParseScopedPage() scope = %q, want %q", page.Scope, tt.scope)
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{raw: "", want: DefaultLimit},
+		{raw: "5", want: 5},
+		{raw: "1000", want: MaxLimit},
+		{raw: "0", wantErr: true},
+		{raw: "-3", wantErr: true},
+		{raw: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) got = %d, want %d", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestSetLinkHeader(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/chirps?sort=desc&cursor=old&limit=2", nil)
+	w := httptest.NewRecorder()
+	SetLinkHeader(w, r, "abc")
+	want := `</api/chirps?cursor=abc&limit=2&sort=desc>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("SetLinkHeader() got = %s, want %s", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	SetLinkHeader(w, r, "")
+	if got := w.Header().Get("Link"); got != "" {
+		t.Errorf("SetLinkHeader() with no cursor got = %s, want empty", got)
+	}
+}
+
+func TestOffsetRoundTrip(t *testing.T) {
+	for _, offset := range []int{0, 20, 12345} {
+		got, err := DecodeOffset(EncodeOffset(offset))
+		if err != nil || got != offset {
+			t.Errorf("DecodeOffset(EncodeOffset(%d)) got = %d, %v", offset, got, err)
+		}
+	}
+	if _, err := DecodeOffset(Cursor{ID: uuid.New()}.Encode()); err == nil {
+		t.Errorf("DecodeOffset() accepted a keyset cursor")
+	}
+}
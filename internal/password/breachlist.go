@@ -0,0 +1,69 @@
+package password
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// BreachList is a BreachedPasswords read from a local file, one uppercase
+// or lowercase SHA-1 hash per line, optionally followed by ":count" as in
+// the Pwned Passwords downloads. Blank lines and lines starting with # are
+// skipped. The whole list is held in memory, so it is meant for the most
+// common breached passwords rather than the full corpus.
+type BreachList struct {
+	ranges map[string][]string
+}
+
+func LoadBreachList(path string) (*BreachList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBreachList(f)
+}
+
+func ReadBreachList(r io.Reader) (*BreachList, error) {
+	list := &BreachList{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 40 {
+			return nil, fmt.Errorf("line %d: not a SHA-1 hash", line)
+		}
+		list.ranges[hash[:5]] = append(list.ranges[hash[:5]], hash[5:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for prefix, suffixes := range list.ranges {
+		slices.Sort(suffixes)
+		list.ranges[prefix] = slices.Compact(suffixes)
+	}
+	return list, nil
+}
+
+func (l *BreachList) Range(prefix string) ([]string, error) {
+	return l.ranges[strings.ToUpper(prefix)], nil
+}
+
+// Len is the number of distinct hashes in the list.
+func (l *BreachList) Len() int {
+	n := 0
+	for _, suffixes := range l.ranges {
+		n += len(suffixes)
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+// Package password holds the rules new account passwords must meet.
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultMinLength = 8
+	// DefaultMaxLength is generous enough for passphrases and password
+	// managers while keeping hashing cheap to ask for.
+	DefaultMaxLength = 256
+)
+
+var (
+	ErrTooShort     = errors.New("password is too short")
+	ErrTooLong      = errors.New("password is too long")
+	ErrBreached     = errors.New("password has appeared in a data breach, choose another")
+	ErrMatchesEmail = errors.New("password must not be your email address")
+)
+
+// IsViolation reports whether err is a reason the policy rejected a
+// password, as opposed to a failure to check it.
+func IsViolation(err error) bool {
+	return errors.Is(err, ErrTooShort) || errors.Is(err, ErrTooLong) ||
+		errors.Is(err, ErrBreached) || errors.Is(err, ErrMatchesEmail)
+}
+
+// BreachedPasswords looks up the SHA-1 hashes of breached passwords by
+// range, like the Pwned Passwords API: Range is given the first five hex
+// digits of a hash and returns the remaining 35 of every breached hash that
+// starts with them, so a remote source would never learn which password is
+// being checked.
+type BreachedPasswords interface {
+	Range(prefix string) ([]string, error)
+}
+
+// Policy is what a new password must satisfy. Length is counted in
+// characters. Breached is optional.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	Breached  BreachedPasswords
+}
+
+var DefaultPolicy = Policy{MinLength: DefaultMinLength, MaxLength: DefaultMaxLength}
+
+// Check returns nil if password is acceptable for the account with the
+// given email. Rejections are recognised by IsViolation; any other error
+// means the breach list couldn't be searched.
+func (p Policy) Check(password, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrTooShort, p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: use at most %d characters", ErrTooLong, p.MaxLength)
+	}
+
+	candidate := strings.TrimSpace(password)
+	localPart, _, _ := strings.Cut(email, "@")
+	if len(email) > 0 && (strings.EqualFold(candidate, email) || strings.EqualFold(candidate, localPart)) {
+		return ErrMatchesEmail
+	}
+
+	if p.Breached == nil {
+		return nil
+	}
+	breached, err := isBreached(p.Breached, password)
+	if err != nil {
+		return fmt.Errorf("checking breached passwords: %w", err)
+	}
+	if breached {
+		return ErrBreached
+	}
+	return nil
+}
+
+func isBreached(source BreachedPasswords, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := source.Range(hash[:5])
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(suffixes, hash[5:]), nil
+}
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+type failingSource struct{}
+
+func (failingSource) Range(prefix string) ([]string, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestPolicyCheck(t *testing.T) {
+	list, err := ReadBreachList(strings.NewReader(
+		"# common passwords\n" +
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n" +
+			"\n" +
+			sha1Hex("correcthorse") + "\n",
+	))
+	if err != nil {
+		t.Fatalf("ReadBreachList() error = %v", err)
+	}
+	policy := Policy{MinLength: 8, MaxLength: 64, Breached: list}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		email    string
+		wantErr  error
+	}{
+		{name: "Acceptable", policy: policy, password: "plaid-otter-lantern", email: "walt@example.com"},
+		{name: "Empty", policy: policy, password: "", email: "walt@example.com", wantErr: ErrTooShort},
+		{name: "Too short", policy: policy, password: "abc1234", email: "walt@example.com", wantErr: ErrTooShort},
+		{name: "Counts characters not bytes", policy: policy, password: "ééééééé", email: "walt@example.com", wantErr: ErrTooShort},
+		{name: "Too long", policy: policy, password: strings.Repeat("a", 65), email: "walt@example.com", wantErr: ErrTooLong},
+		{name: "Email", policy: policy, password: "Walt@Example.com", email: "walt@example.com", wantErr: ErrMatchesEmail},
+		{name: "Email local part", policy: policy, password: "walterwhite", email: "walterwhite@example.com", wantErr: ErrMatchesEmail},
+		{name: "Breached", policy: policy, password: "password", email: "walt@example.com", wantErr: ErrBreached},
+		{name: "Breached lowercase hash in list", policy: policy, password: "correcthorse", email: "walt@example.com", wantErr: ErrBreached},
+		{name: "No breach list", policy: Policy{MinLength: 8}, password: "password", email: "walt@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password, tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !IsViolation(err) {
+				t.Errorf("IsViolation(%v) = false", err)
+			}
+		})
+	}
+
+	failing := Policy{MinLength: 8, Breached: failingSource{}}
+	if err := failing.Check("plaid-otter-lantern", ""); err == nil || IsViolation(err) {
+		t.Errorf("Check() error = %v, want a lookup failure", err)
+	}
+}
+
+func TestReadBreachList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantLen int
+		wantErr bool
+	}{
+		{name: "Hashes with counts", input: sha1Hex("a") + ":1\n" + sha1Hex("b") + ":2\n", wantLen: 2},
+		{name: "Duplicates", input: sha1Hex("a") + "\n" + strings.ToUpper(sha1Hex("a")) + "\n", wantLen: 1},
+		{name: "Comments and blanks", input: "# header\n\n" + sha1Hex("a") + "\n", wantLen: 1},
+		{name: "Not hex", input: strings.Repeat("z", 40) + "\n", wantErr: true},
+		{name: "Wrong length", input: "5BAA61E4\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ReadBreachList(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadBreachList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && list.Len() != tt.wantLen {
+				t.Errorf("Len() = %d, want %d", list.Len(), tt.wantLen)
+			}
+		})
+	}
+}
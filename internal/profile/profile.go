@@ -0,0 +1,89 @@
+// Package profile holds the rules for the public parts of a user account:
+// handles, display names and bios.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MinHandleLength      = 3
+	MaxHandleLength      = 30
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 160
+)
+
+var (
+	ErrInvalidHandle  = errors.New("handles must be 3-30 letters, digits or underscores and start with a letter")
+	ErrReservedHandle = errors.New("handle is reserved")
+)
+
+// reserved handles would collide with routes or impersonate staff. Anything
+// starting with "chirpy" is reserved too.
+var reserved = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "app": true,
+	"everyone": true, "help": true, "here": true, "login": true,
+	"logout": true, "me": true, "mod": true, "moderator": true,
+	"null": true, "official": true, "root": true, "security": true,
+	"settings": true, "signup": true, "staff": true, "support": true,
+	"system": true, "undefined": true,
+}
+
+// NormalizeHandle returns the form handles are compared in: without a
+// leading @ and lowercased.
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(handle, "@"))
+}
+
+// ValidateHandle checks a handle as the user typed it. Handles are ASCII so
+// they survive being @mentioned, and start with a letter so they can never
+// be mistaken for an ID.
+func ValidateHandle(handle string) error {
+	if len(handle) < MinHandleLength || len(handle) > MaxHandleLength {
+		return ErrInvalidHandle
+	}
+	for i, r := range handle {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_'):
+		default:
+			return ErrInvalidHandle
+		}
+	}
+	normalized := NormalizeHandle(handle)
+	if reserved[normalized] || strings.HasPrefix(normalized, "chirpy") {
+		return ErrReservedHandle
+	}
+	return nil
+}
+
+// CleanDisplayName trims surrounding space and rejects names that are too
+// long or contain control characters.
+func CleanDisplayName(name string) (string, error) {
+	return cleanText("display name", name, MaxDisplayNameLength, false)
+}
+
+// CleanBio is like CleanDisplayName but allows line breaks.
+func CleanBio(bio string) (string, error) {
+	return cleanText("bio", bio, MaxBioLength, true)
+}
+
+func cleanText(field, s string, max int, allowNewlines bool) (string, error) {
+	s = strings.TrimSpace(s)
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("%s is not valid UTF-8", field)
+	}
+	if utf8.RuneCountInString(s) > max {
+		return "", fmt.Errorf("%s must be at most %d characters", field, max)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) && !(allowNewlines && r == '\n') {
+			return "", fmt.Errorf("%s contains invalid characters", field)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateHandle(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  string
+		wantErr error
+	}{
+		{name: "Simple", handle: "gopher"},
+		{name: "Mixed case digits and underscore", handle: "Go_Gopher_42"},
+		{name: "Too short", handle: "ab", wantErr: ErrInvalidHandle},
+		{name: "Too long", handle: strings.Repeat("a", 31), wantErr: ErrInvalidHandle},
+		{name: "Starts with digit", handle: "1gopher", wantErr: ErrInvalidHandle},
+		{name: "Starts with underscore", handle: "_gopher", wantErr: ErrInvalidHandle},
+		{name: "Hyphen", handle: "go-pher", wantErr: ErrInvalidHandle},
+		{name: "Non-ASCII", handle: "gophér", wantErr: ErrInvalidHandle},
+		{name: "Leading at sign", handle: "@gopher", wantErr: ErrInvalidHandle},
+		{name: "Reserved", handle: "Admin", wantErr: ErrReservedHandle},
+		{name: "Reserved prefix", handle: "ChirpyTeam", wantErr: ErrReservedHandle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHandle(tt.handle)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateHandle(%q) error = %v, want %v", tt.handle, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeHandle(t *testing.T) {
+	if got := NormalizeHandle("@Go_Gopher"); got != "go_gopher" {
+		t.Errorf("NormalizeHandle() = %q, want %q", got, "go_gopher")
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name    string
+		clean   func(string) (string, error)
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Display name is trimmed", clean: CleanDisplayName, input: "  Gopher  ", want: "Gopher"},
+		{name: "Display name may be cleared", clean: CleanDisplayName, input: "", want: ""},
+		{name: "Display name counts runes", clean: CleanDisplayName, input: strings.Repeat("é", 50), want: strings.Repeat("é", 50)},
+		{name: "Display name too long", clean: CleanDisplayName, input: strings.Repeat("a", 51), wantErr: true},
+		{name: "Display name rejects newline", clean: CleanDisplayName, input: "Go\npher", wantErr: true},
+		{name: "Bio allows newline", clean: CleanBio, input: "Gopher\nfan", want: "Gopher\nfan"},
+		{name: "Bio rejects other controls", clean: CleanBio, input: "Gopher\x00", wantErr: true},
+		{name: "Bio too long", clean: CleanBio, input: strings.Repeat("a", 161), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.clean(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
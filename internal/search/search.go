@@ -0,0 +1,116 @@
+package search
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// MaxTerms caps how many terms a single query may contain.
+const MaxTerms = 16
+
+var ErrEmptyQuery = errors.New("query has no searchable terms")
+
+// BuildTSQuery turns a user supplied search string into a to_tsquery
+// expression. Words are ANDed together, "quoted text" becomes a phrase
+// match and a trailing * makes a word a prefix match. Anything that is
+// not a letter or digit is dropped, so the result is always valid syntax.
+func BuildTSQuery(q string) (string, error) {
+	var terms []string
+	rest := q
+	for len(rest) > 0 && len(terms) < MaxTerms {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if len(rest) == 0 {
+			break
+		}
+
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			var phrase string
+			if end < 0 {
+				phrase, rest = rest[1:], ""
+			} else {
+				phrase, rest = rest[1:end+1], rest[end+2:]
+			}
+			if term := phraseTerm(phrase); term != "" {
+				terms = append(terms, term)
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		var word string
+		if end < 0 {
+			word, rest = rest, ""
+		} else {
+			word, rest = rest[:end], rest[end:]
+		}
+		if term := wordTerm(word); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return "", ErrEmptyQuery
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+func wordTerm(word string) string {
+	prefix := strings.HasSuffix(word, "*")
+	lexeme := clean(word)
+	if lexeme == "" {
+		return ""
+	}
+	if prefix {
+		return lexeme + ":*"
+	}
+	return lexeme
+}
+
+func phraseTerm(phrase string) string {
+	var lexemes []string
+	for _, word := range strings.Fields(phrase) {
+		if lexeme := clean(word); lexeme != "" {
+			lexemes = append(lexemes, lexeme)
+		}
+	}
+	switch len(lexemes) {
+	case 0:
+		return ""
+	case 1:
+		return lexemes[0]
+	}
+	return "(" + strings.Join(lexemes, " <-> ") + ")"
+}
+
+func clean(word string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, word))
+}
+
+// LikePrefix escapes q for use as an ILIKE pattern that matches values
+// starting with q.
+func LikePrefix(q string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(q) + "%"
+}
+
+// ts_headline is asked to wrap matches in these control characters rather
+// than HTML so the chirp body can be escaped before markup is added.
+const (
+	HighlightStart = "\x01"
+	HighlightStop  = "\x02"
+)
+
+// HighlightHTML escapes a ts_headline snippet and turns its match markers
+// into <mark> elements.
+func HighlightHTML(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, HighlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, HighlightStop, "</mark>")
+}
@@ -0,0 +1,48 @@
+package search
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Single word", query: "gophers", want: "gophers"},
+		{name: "Words are ANDed", query: "Go  gophers", want: "go & gophers"},
+		{name: "Prefix match", query: "goph*", want: "goph:*"},
+		{name: "Phrase match", query: `"hello big world" again`, want: "(hello <-> big <-> world) & again"},
+		{name: "Unterminated phrase", query: `"hello world`, want: "(hello <-> world)"},
+		{name: "Operators are stripped", query: "a&b | !c:* (d)", want: "ab & c:* & d"},
+		{name: "Unicode letters kept", query: "café", want: "café"},
+		{name: "Only punctuation", query: "&& !!", wantErr: true},
+		{name: "Empty", query: "   ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildTSQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BuildTSQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BuildTSQuery() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLikePrefix(t *testing.T) {
+	if got := LikePrefix(`50%_off\`); got != `50\%\_off\\%` {
+		t.Errorf("LikePrefix() got = %q", got)
+	}
+}
+
+func TestHighlightHTML(t *testing.T) {
+	snippet := "<b>" + HighlightStart + "gophers" + HighlightStop + "</b> & friends"
+	want := "&lt;b&gt;<mark>gophers</mark>&lt;/b&gt; &amp; friends"
+	if got := HighlightHTML(snippet); got != want {
+		t.Errorf("HighlightHTML() got = %q, want %q", got, want)
+	}
+}
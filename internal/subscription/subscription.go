@@ -0,0 +1,153 @@
+// Package subscription tracks Chirpy Red subscriptions. The changes Polka's
+// events ask for are worked out here on a database.Subscription, which the
+// caller then saves, and a Sweeper ends subscriptions once they lapse.
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+const (
+	StatusActive = "active"
+	// StatusPastDue means a renewal payment failed. The subscription keeps
+	// running until it expires while the payment is retried.
+	StatusPastDue = "past_due"
+	// StatusCanceled means the subscription won't renew but runs until it
+	// expires.
+	StatusCanceled = "canceled"
+	StatusEnded    = "ended"
+)
+
+// DefaultPlan is recorded when an upgrade doesn't name a plan.
+const DefaultPlan = "chirpy_red"
+
+// Entitled reports whether sub makes its user Chirpy Red at now. It does
+// not wait for the Sweeper, so a subscription stops counting the moment it
+// expires.
+func Entitled(sub database.Subscription, now time.Time) bool {
+	switch sub.Status {
+	case StatusActive, StatusPastDue, StatusCanceled:
+		return !sub.ExpiresAt.Valid || now.Before(sub.ExpiresAt.Time)
+	}
+	return false
+}
+
+// Stale reports whether an event that happened at occurredAt is older than
+// the last one applied to sub. Polka doesn't promise to deliver events in
+// order, so a stale event is ignored rather than undoing a later change.
+func Stale(sub database.Subscription, occurredAt time.Time) bool {
+	return sub.LastEventAt.Valid && occurredAt.Before(sub.LastEventAt.Time)
+}
+
+// Activate applies an upgrade or renewal paid up to expiresAt, or with no
+// end if expiresAt is not valid. sub is the user's current subscription,
+// or one with only UserID set if they have never had one. A subscription
+// that is still running keeps its start and is never shortened, so a
+// renewal delivered late can't undo a newer one.
+func Activate(sub database.Subscription, plan string, expiresAt sql.NullTime, now time.Time) database.Subscription {
+	if len(plan) == 0 {
+		plan = sub.Plan
+	}
+	if len(plan) == 0 {
+		plan = DefaultPlan
+	}
+	if !Entitled(sub, now) {
+		sub.StartedAt = now
+	} else if expiresAt.Valid && sub.ExpiresAt.Valid && sub.ExpiresAt.Time.After(expiresAt.Time) {
+		expiresAt = sub.ExpiresAt
+	}
+	sub.Plan = plan
+	sub.Status = StatusActive
+	sub.ExpiresAt = expiresAt
+	sub.CanceledAt = sql.NullTime{}
+	sub.EndedAt = sql.NullTime{}
+	return sub
+}
+
+// Cancel stops sub from renewing. It keeps running until it expires, or
+// ends at once if it has no expiry. It reports false if sub was not
+// running.
+func Cancel(sub database.Subscription, now time.Time) (database.Subscription, bool) {
+	if !Entitled(sub, now) || sub.Status == StatusCanceled {
+		return sub, false
+	}
+	if !sub.ExpiresAt.Valid {
+		return End(sub, now)
+	}
+	sub.Status = StatusCanceled
+	sub.CanceledAt = sql.NullTime{Time: now, Valid: true}
+	return sub, true
+}
+
+// PaymentFailed marks an active sub as past due. It reports false if sub
+// was not active.
+func PaymentFailed(sub database.Subscription, now time.Time) (database.Subscription, bool) {
+	if !Entitled(sub, now) || sub.Status != StatusActive {
+		return sub, false
+	}
+	sub.Status = StatusPastDue
+	return sub, true
+}
+
+// End ends sub at once, as for a downgrade. It reports false if sub had
+// already ended.
+func End(sub database.Subscription, now time.Time) (database.Subscription, bool) {
+	if len(sub.Status) == 0 || sub.Status == StatusEnded {
+		return sub, false
+	}
+	sub.Status = StatusEnded
+	sub.EndedAt = sql.NullTime{Time: now, Valid: true}
+	if !sub.ExpiresAt.Valid || sub.ExpiresAt.Time.After(now) {
+		sub.ExpiresAt = sql.NullTime{Time: now, Valid: true}
+	}
+	return sub, true
+}
+
+type Store interface {
+	EndExpiredSubscriptions(ctx context.Context) ([]database.Subscription, error)
+}
+
+// Sweeper marks subscriptions that have expired as ended. Entitled already
+// ignores them, so this keeps the stored status honest rather than being
+// what takes Chirpy Red away.
+type Sweeper struct {
+	store    Store
+	interval time.Duration
+}
+
+func NewSweeper(store Store, interval time.Duration) *Sweeper {
+	return &Sweeper{store: store, interval: interval}
+}
+
+// Run sweeps immediately and then every interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("subscription: sweep failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep ends every expired subscription and returns those it ended.
+func (s *Sweeper) Sweep(ctx context.Context) ([]database.Subscription, error) {
+	ended, err := s.store.EndExpiredSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range ended {
+		log.Printf("subscription: %s plan %s ended", sub.UserID, sub.Plan)
+	}
+	return ended, nil
+}
@@ -0,0 +1,236 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func at(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+func TestEntitled(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  database.Subscription
+		want bool
+	}{
+		{name: "Never subscribed", sub: database.Subscription{}, want: false},
+		{name: "Active", sub: database.Subscription{Status: StatusActive, ExpiresAt: at(now.Add(time.Hour))}, want: true},
+		{name: "Active without expiry", sub: database.Subscription{Status: StatusActive}, want: true},
+		{name: "Past due", sub: database.Subscription{Status: StatusPastDue, ExpiresAt: at(now.Add(time.Hour))}, want: true},
+		{name: "Canceled but running", sub: database.Subscription{Status: StatusCanceled, ExpiresAt: at(now.Add(time.Hour))}, want: true},
+		{name: "Expired but not swept", sub: database.Subscription{Status: StatusActive, ExpiresAt: at(now)}, want: false},
+		{name: "Ended", sub: database.Subscription{Status: StatusEnded, ExpiresAt: at(now.Add(-time.Hour))}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Entitled(tt.sub, now); got != tt.want {
+				t.Errorf("Entitled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStale(t *testing.T) {
+	tests := []struct {
+		name       string
+		sub        database.Subscription
+		occurredAt time.Time
+		want       bool
+	}{
+		{name: "No event applied yet", sub: database.Subscription{Status: StatusActive}, occurredAt: now, want: false},
+		{name: "Newer event", sub: database.Subscription{LastEventAt: at(now.Add(-time.Minute))}, occurredAt: now, want: false},
+		{name: "Same time", sub: database.Subscription{LastEventAt: at(now)}, occurredAt: now, want: false},
+		{name: "Older event", sub: database.Subscription{LastEventAt: at(now)}, occurredAt: now.Add(-time.Minute), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Stale(tt.sub, tt.occurredAt); got != tt.want {
+				t.Errorf("Stale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActivate(t *testing.T) {
+	started := now.Add(-30 * 24 * time.Hour)
+	running := database.Subscription{Plan: "monthly", Status: StatusActive, StartedAt: started, ExpiresAt: at(now.Add(24 * time.Hour))}
+
+	tests := []struct {
+		name        string
+		sub         database.Subscription
+		plan        string
+		expiresAt   sql.NullTime
+		wantPlan    string
+		wantStarted time.Time
+		wantExpires sql.NullTime
+	}{
+		{
+			name:        "First upgrade",
+			sub:         database.Subscription{},
+			expiresAt:   at(now.Add(30 * 24 * time.Hour)),
+			wantPlan:    DefaultPlan,
+			wantStarted: now,
+			wantExpires: at(now.Add(30 * 24 * time.Hour)),
+		},
+		{
+			name:        "Renewal extends",
+			sub:         running,
+			expiresAt:   at(now.Add(31 * 24 * time.Hour)),
+			wantPlan:    "monthly",
+			wantStarted: started,
+			wantExpires: at(now.Add(31 * 24 * time.Hour)),
+		},
+		{
+			name:        "Late renewal doesn't shorten",
+			sub:         running,
+			expiresAt:   at(now.Add(-24 * time.Hour)),
+			wantPlan:    "monthly",
+			wantStarted: started,
+			wantExpires: running.ExpiresAt,
+		},
+		{
+			name:        "Plan change",
+			sub:         running,
+			plan:        "yearly",
+			expiresAt:   at(now.Add(365 * 24 * time.Hour)),
+			wantPlan:    "yearly",
+			wantStarted: started,
+			wantExpires: at(now.Add(365 * 24 * time.Hour)),
+		},
+		{
+			name:        "Open-ended upgrade",
+			sub:         running,
+			wantPlan:    "monthly",
+			wantStarted: started,
+		},
+		{
+			name:        "Resubscribe after ending",
+			sub:         database.Subscription{Plan: "monthly", Status: StatusEnded, StartedAt: started, ExpiresAt: at(now.Add(-time.Hour)), EndedAt: at(now.Add(-time.Hour))},
+			expiresAt:   at(now.Add(30 * 24 * time.Hour)),
+			wantPlan:    "monthly",
+			wantStarted: now,
+			wantExpires: at(now.Add(30 * 24 * time.Hour)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Activate(tt.sub, tt.plan, tt.expiresAt, now)
+			if got.Status != StatusActive || got.Plan != tt.wantPlan || !got.StartedAt.Equal(tt.wantStarted) || got.ExpiresAt != tt.wantExpires {
+				t.Errorf("Activate() got = %+v", got)
+			}
+			if got.CanceledAt.Valid || got.EndedAt.Valid {
+				t.Errorf("Activate() left canceled_at or ended_at set: %+v", got)
+			}
+			if !Entitled(got, now) {
+				t.Errorf("Activate() result not entitled")
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	expires := at(now.Add(24 * time.Hour))
+
+	got, changed := Cancel(database.Subscription{Status: StatusPastDue, ExpiresAt: expires}, now)
+	if !changed || got.Status != StatusCanceled || got.CanceledAt != at(now) || got.ExpiresAt != expires {
+		t.Errorf("Cancel() got = %+v, %v", got, changed)
+	}
+	if !Entitled(got, now) || Entitled(got, expires.Time) {
+		t.Errorf("Cancel() should keep the subscription running until it expires")
+	}
+
+	got, changed = Cancel(database.Subscription{Status: StatusActive}, now)
+	if !changed || got.Status != StatusEnded || got.EndedAt != at(now) || Entitled(got, now) {
+		t.Errorf("Cancel() without expiry got = %+v, %v, want ended", got, changed)
+	}
+
+	for _, sub := range []database.Subscription{{}, {Status: StatusEnded}, {Status: StatusCanceled, ExpiresAt: expires}} {
+		if _, changed := Cancel(sub, now); changed {
+			t.Errorf("Cancel(%q) changed = true, want false", sub.Status)
+		}
+	}
+}
+
+func TestPaymentFailed(t *testing.T) {
+	expires := at(now.Add(24 * time.Hour))
+
+	got, changed := PaymentFailed(database.Subscription{Status: StatusActive, ExpiresAt: expires}, now)
+	if !changed || got.Status != StatusPastDue || !Entitled(got, now) {
+		t.Errorf("PaymentFailed() got = %+v, %v", got, changed)
+	}
+
+	for _, sub := range []database.Subscription{{}, {Status: StatusEnded}, {Status: StatusCanceled, ExpiresAt: expires}, {Status: StatusActive, ExpiresAt: at(now)}} {
+		if _, changed := PaymentFailed(sub, now); changed {
+			t.Errorf("PaymentFailed(%q) changed = true, want false", sub.Status)
+		}
+	}
+}
+
+func TestEnd(t *testing.T) {
+	got, changed := End(database.Subscription{Status: StatusActive, ExpiresAt: at(now.Add(24 * time.Hour))}, now)
+	if !changed || got.Status != StatusEnded || got.EndedAt != at(now) || got.ExpiresAt != at(now) || Entitled(got, now) {
+		t.Errorf("End() got = %+v, %v", got, changed)
+	}
+
+	// Ending something that already lapsed keeps when it lapsed.
+	lapsed := at(now.Add(-time.Hour))
+	got, changed = End(database.Subscription{Status: StatusCanceled, ExpiresAt: lapsed}, now)
+	if !changed || got.ExpiresAt != lapsed {
+		t.Errorf("End() lapsed got = %+v, %v", got, changed)
+	}
+
+	for _, sub := range []database.Subscription{{}, {Status: StatusEnded}} {
+		if _, changed := End(sub, now); changed {
+			t.Errorf("End(%q) changed = true, want false", sub.Status)
+		}
+	}
+}
+
+type fakeStore struct {
+	ended []database.Subscription
+	err   error
+}
+
+func (f *fakeStore) EndExpiredSubscriptions(ctx context.Context) ([]database.Subscription, error) {
+	return f.ended, f.err
+}
+
+func TestSweep(t *testing.T) {
+	store := &fakeStore{ended: []database.Subscription{{UserID: uuid.New(), Plan: "monthly", Status: StatusEnded}}}
+	sweeper := NewSweeper(store, time.Minute)
+
+	ended, err := sweeper.Sweep(context.Background())
+	if err != nil || len(ended) != 1 {
+		t.Fatalf("Sweep() got = %+v, %v", ended, err)
+	}
+
+	store.err = errors.New("database down")
+	if _, err := sweeper.Sweep(context.Background()); err == nil {
+		t.Errorf("Sweep() expected error")
+	}
+}
+
+func TestSweeperRunStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewSweeper(&fakeStore{}, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after ctx was cancelled")
+	}
+}
@@ -0,0 +1,79 @@
+// Package throttle decides how long to lock out a login key, such as an
+// account or a client address, after repeated failed attempts.
+package throttle
+
+import (
+	"strings"
+	"time"
+)
+
+// Policy is an exponential backoff: the first FreeAttempts failures cost
+// nothing, the next locks the key for BaseDelay, and each failure after that
+// doubles the lockout up to MaxDelay. Failures older than ResetAfter are
+// forgotten.
+type Policy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	ResetAfter   time.Duration
+}
+
+var (
+	// AccountPolicy applies to failures against one email address, whether
+	// or not an account uses it.
+	AccountPolicy = Policy{FreeAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: time.Hour, ResetAfter: 24 * time.Hour}
+	// IPPolicy applies to failures from one client address across all
+	// accounts. It is looser because many users can share an address.
+	IPPolicy = Policy{FreeAttempts: 20, BaseDelay: 30 * time.Second, MaxDelay: time.Hour, ResetAfter: 24 * time.Hour}
+	// ResetPolicy applies to password reset requests for one email
+	// address. Every request counts, since each one sends an email.
+	ResetPolicy = Policy{FreeAttempts: 3, BaseDelay: 5 * time.Minute, MaxDelay: 24 * time.Hour, ResetAfter: 24 * time.Hour}
+	// ResetIPPolicy applies to password reset requests from one client
+	// address across all email addresses.
+	ResetIPPolicy = Policy{FreeAttempts: 10, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: 24 * time.Hour}
+)
+
+// Lockout returns how long a key is locked out after its failures-th
+// consecutive failure.
+func (p Policy) Lockout(failures int) time.Duration {
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// AccountKey is the throttle key for an email address.
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey is the throttle key for a client address.
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ResetKey is the throttle key for password reset requests for an email
+// address. It is kept apart from AccountKey so asking for resets can't lock
+// anyone out of logging in.
+func ResetKey(email string) string {
+	return "reset:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ResetIPKey is the throttle key for password reset requests from a client
+// address.
+func ResetIPKey(ip string) string {
+	return "reset-ip:" + ip
+}
+
+// SessionKey is the throttle key for a signed-in session, counting wrong
+// second-factor codes entered in it.
+func SessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
@@ -0,0 +1,49 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 3, want: 0},
+		{failures: 4, want: time.Second},
+		{failures: 5, want: 2 * time.Second},
+		{failures: 6, want: 4 * time.Second},
+		{failures: 7, want: 8 * time.Second},
+		{failures: 8, want: 10 * time.Second},
+		{failures: 1000, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Lockout(tt.failures); got != tt.want {
+			t.Errorf("Lockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	if AccountKey(" Gopher@Example.com ") != AccountKey("gopher@example.com") {
+		t.Errorf("AccountKey() is sensitive to case or spaces")
+	}
+	if AccountKey("203.0.113.7") == IPKey("203.0.113.7") {
+		t.Errorf("AccountKey() and IPKey() share a namespace")
+	}
+	if SessionKey("203.0.113.7") == IPKey("203.0.113.7") {
+		t.Errorf("SessionKey() and IPKey() share a namespace")
+	}
+	if ResetKey(" Gopher@Example.com ") != ResetKey("gopher@example.com") {
+		t.Errorf("ResetKey() is sensitive to case or spaces")
+	}
+	if ResetKey("gopher@example.com") == AccountKey("gopher@example.com") {
+		t.Errorf("ResetKey() and AccountKey() share a namespace")
+	}
+	if ResetIPKey("203.0.113.7") == IPKey("203.0.113.7") {
+		t.Errorf("ResetIPKey() and IPKey() share a namespace")
+	}
+}
@@ -0,0 +1,185 @@
+package trending
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+type Config struct {
+	// Window is the recent period whose activity is being ranked.
+	Window time.Duration
+	// Baseline is the longer period used to work out how busy a tag
+	// normally is. It must be longer than Window.
+	Baseline time.Duration
+	// HalfLife controls how quickly uses inside Window lose weight.
+	HalfLife time.Duration
+	// MinUses is how many uses a tag needs inside Window to be considered.
+	MinUses int64
+	// Refresh is how often the background loop recomputes the rankings.
+	Refresh time.Duration
+	// MaxTrends caps how many tags are kept in memory.
+	MaxTrends int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Window:    time.Hour,
+		Baseline:  24 * time.Hour,
+		HalfLife:  30 * time.Minute,
+		MinUses:   2,
+		Refresh:   time.Minute,
+		MaxTrends: 50,
+	}
+}
+
+// Bucket is the number of times a tag was used in one minute.
+type Bucket struct {
+	Tag        string
+	MinutesAgo int
+	Uses       int64
+}
+
+type Trend struct {
+	Tag          string  `json:"tag"`
+	Score        float64 `json:"score"`
+	RecentUses   int64   `json:"recent_uses"`
+	BaselineUses int64   `json:"baseline_uses"`
+}
+
+// Compute ranks tags by how far their decayed activity in the recent window
+// exceeds what their baseline rate predicts, scaled like a z-score so that
+// a jump from 1 to 10 uses beats a jump from 1000 to 1010.
+func Compute(buckets []Bucket, cfg Config) []Trend {
+	type tally struct {
+		decayed  float64
+		recent   int64
+		baseline int64
+	}
+	tallies := map[string]*tally{}
+	for _, b := range buckets {
+		t, ok := tallies[b.Tag]
+		if !ok {
+			t = &tally{}
+			tallies[b.Tag] = t
+		}
+		age := time.Duration(b.MinutesAgo)*time.Minute + 30*time.Second
+		if age > cfg.Baseline {
+			continue
+		}
+		t.baseline += b.Uses
+		if age <= cfg.Window {
+			t.recent += b.Uses
+			t.decayed += float64(b.Uses) * math.Pow(0.5, float64(age)/float64(cfg.HalfLife))
+		}
+	}
+
+	windowShare := float64(cfg.Window) / float64(cfg.Baseline)
+	trends := make([]Trend, 0, len(tallies))
+	for tag, t := range tallies {
+		if t.recent < cfg.MinUses {
+			continue
+		}
+		expected := float64(t.baseline-t.recent) * windowShare
+		score := (t.decayed - expected) / math.Sqrt(expected+1)
+		if score <= 0 {
+			continue
+		}
+		trends = append(trends, Trend{
+			Tag:          tag,
+			Score:        math.Round(score*1000) / 1000,
+			RecentUses:   t.recent,
+			BaselineUses: t.baseline,
+		})
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		if trends[i].RecentUses != trends[j].RecentUses {
+			return trends[i].RecentUses > trends[j].RecentUses
+		}
+		return trends[i].Tag < trends[j].Tag
+	})
+	if cfg.MaxTrends > 0 && len(trends) > cfg.MaxTrends {
+		trends = trends[:cfg.MaxTrends]
+	}
+	return trends
+}
+
+type Store interface {
+	CountHashtagUsesSince(ctx context.Context, windowSeconds float64) ([]database.CountHashtagUsesSinceRow, error)
+}
+
+// Tracker keeps the latest rankings in memory so that reading them never
+// touches the database.
+type Tracker struct {
+	cfg   Config
+	store Store
+
+	mu        sync.RWMutex
+	trends    []Trend
+	updatedAt time.Time
+}
+
+func NewTracker(cfg Config, store Store) *Tracker {
+	return &Tracker{cfg: cfg, store: store}
+}
+
+func (t *Tracker) Config() Config {
+	return t.cfg
+}
+
+// Run refreshes the rankings immediately and then every cfg.Refresh until
+// ctx is cancelled. Failed refreshes keep serving the previous rankings.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.cfg.Refresh)
+	defer ticker.Stop()
+	for {
+		if err := t.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("trending: refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tracker) Refresh(ctx context.Context) error {
+	rows, err := t.store.CountHashtagUsesSince(ctx, t.cfg.Baseline.Seconds())
+	if err != nil {
+		return err
+	}
+	buckets := make([]Bucket, 0, len(rows))
+	for _, row := range rows {
+		buckets = append(buckets, Bucket{Tag: row.Tag, MinutesAgo: int(row.MinutesAgo), Uses: row.Uses})
+	}
+	trends := Compute(buckets, t.cfg)
+
+	t.mu.Lock()
+	t.trends = trends
+	t.updatedAt = time.Now().UTC()
+	t.mu.Unlock()
+	return nil
+}
+
+// Top returns up to n of the current trends and when they were computed.
+// The zero time means no refresh has succeeded yet.
+func (t *Tracker) Top(n int) ([]Trend, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if n > len(t.trends) {
+		n = len(t.trends)
+	}
+	top := make([]Trend, n)
+	copy(top, t.trends[:n])
+	return top, t.updatedAt
+}
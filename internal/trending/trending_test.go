@@ -0,0 +1,94 @@
+package trending
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samuelhamann/chirpy/internal/database"
+)
+
+func TestCompute(t *testing.T) {
+	cfg := DefaultConfig()
+	buckets := []Bucket{
+		// A burst in the last few minutes from nothing.
+		{Tag: "breaking", MinutesAgo: 1, Uses: 6},
+		{Tag: "breaking", MinutesAgo: 3, Uses: 4},
+		// Steady chatter: 10 uses an hour all day long.
+		{Tag: "golang", MinutesAgo: 5, Uses: 10},
+		{Tag: "golang", MinutesAgo: 120, Uses: 100},
+		{Tag: "golang", MinutesAgo: 600, Uses: 130},
+		// Busy long ago but quiet now.
+		{Tag: "yesterday", MinutesAgo: 1200, Uses: 500},
+		// Too few uses to count.
+		{Tag: "lonely", MinutesAgo: 2, Uses: 1},
+		// Older than the baseline window.
+		{Tag: "ancient", MinutesAgo: 3000, Uses: 50},
+	}
+
+	got := Compute(buckets, cfg)
+	if len(got) != 1 || got[0].Tag != "breaking" {
+		t.Fatalf("Compute() got = %+v, want only breaking", got)
+	}
+	if got[0].RecentUses != 10 || got[0].BaselineUses != 10 {
+		t.Errorf("Compute() got uses = %d/%d, want 10/10", got[0].RecentUses, got[0].BaselineUses)
+	}
+}
+
+func TestComputeDecayFavoursNewerUses(t *testing.T) {
+	cfg := DefaultConfig()
+	got := Compute([]Bucket{
+		{Tag: "fresh", MinutesAgo: 0, Uses: 5},
+		{Tag: "stale", MinutesAgo: 55, Uses: 5},
+	}, cfg)
+	if len(got) != 2 || got[0].Tag != "fresh" || got[0].Score <= got[1].Score {
+		t.Errorf("Compute() got = %+v, want fresh ranked above stale", got)
+	}
+}
+
+func TestComputeMaxTrends(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxTrends = 2
+	got := Compute([]Bucket{
+		{Tag: "a", Uses: 3},
+		{Tag: "b", Uses: 4},
+		{Tag: "c", Uses: 5},
+	}, cfg)
+	if len(got) != 2 || got[0].Tag != "c" || got[1].Tag != "b" {
+		t.Errorf("Compute() got = %+v, want [c b]", got)
+	}
+}
+
+type fakeStore struct {
+	rows []database.CountHashtagUsesSinceRow
+	err  error
+}
+
+func (f *fakeStore) CountHashtagUsesSince(ctx context.Context, windowSeconds float64) ([]database.CountHashtagUsesSinceRow, error) {
+	return f.rows, f.err
+}
+
+func TestTrackerRefresh(t *testing.T) {
+	store := &fakeStore{rows: []database.CountHashtagUsesSinceRow{{Tag: "go", MinutesAgo: 1, Uses: 3}}}
+	tracker := NewTracker(DefaultConfig(), store)
+
+	if trends, updatedAt := tracker.Top(10); len(trends) != 0 || !updatedAt.IsZero() {
+		t.Fatalf("Top() before refresh got = %+v, %v", trends, updatedAt)
+	}
+	if err := tracker.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	trends, updatedAt := tracker.Top(10)
+	if len(trends) != 1 || trends[0].Tag != "go" || updatedAt.IsZero() {
+		t.Fatalf("Top() after refresh got = %+v, %v", trends, updatedAt)
+	}
+
+	// A failed refresh keeps the last good rankings.
+	store.err = errors.New("database down")
+	if err := tracker.Refresh(context.Background()); err == nil {
+		t.Fatalf("Refresh() expected error")
+	}
+	if trends, _ := tracker.Top(10); len(trends) != 1 {
+		t.Errorf("Top() after failed refresh got = %+v", trends)
+	}
+}
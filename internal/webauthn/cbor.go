@@ -0,0 +1,183 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// This is the subset of CBOR (RFC 8949) that authenticators use in
+// attestation objects and COSE keys: definite-length integers, byte and
+// text strings, arrays, maps, and the simple values false, true and null.
+// Floats, tags and indefinite lengths never appear there and are rejected.
+//
+// Decoded values are int64, []byte, string, []interface{},
+// map[interface{}]interface{} with int64 or string keys, bool, or nil.
+
+const maxCBORDepth = 16
+
+var ErrInvalidCBOR = errors.New("invalid CBOR")
+
+// decodeCBOR decodes the first CBOR item in data, returning it and the
+// number of bytes it took up. Whatever follows is left for the caller.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := cborDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.pos, nil
+}
+
+// decodeCBORAll decodes data, which must hold exactly one CBOR item.
+func decodeCBORAll(data []byte) (interface{}, error) {
+	v, n, err := decodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidCBOR, len(data)-n)
+	}
+	return v, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) fail(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at byte %d: %s", ErrInvalidCBOR, d.pos, fmt.Sprintf(format, args...))
+}
+
+// head reads an item's initial byte and argument.
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, d.fail("unexpected end of data")
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, d.fail("unsupported additional information %d", info)
+	}
+	if len(d.data)-d.pos < size {
+		return 0, 0, d.fail("unexpected end of data")
+	}
+	buf := make([]byte, 8)
+	copy(buf[8-size:], d.data[d.pos:d.pos+size])
+	d.pos += size
+	return major, binary.BigEndian.Uint64(buf), nil
+}
+
+// length checks that n more items or bytes could fit in what is left, so
+// a forged length can't make the decoder allocate huge buffers.
+func (d *cborDecoder) length(n uint64) (int, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return 0, d.fail("length %d exceeds the remaining data", n)
+	}
+	return int(n), nil
+}
+
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, d.fail("nested too deeply")
+	}
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, d.fail("integer overflows int64")
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, d.fail("integer overflows int64")
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		b := d.data[d.pos : d.pos+n]
+		d.pos += n
+		if major == 2 {
+			return append([]byte(nil), b...), nil
+		}
+		if !utf8.Valid(b) {
+			return nil, d.fail("text string is not UTF-8")
+		}
+		return string(b), nil
+	case 4:
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, d.fail("map key must be an integer or text string")
+			}
+			if _, ok := m[key]; ok {
+				return nil, d.fail("duplicate map key %v", key)
+			}
+			value, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	case 7:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		}
+		return nil, d.fail("unsupported simple value or float")
+	}
+	return nil, d.fail("unsupported major type %d", major)
+}
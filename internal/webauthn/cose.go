@@ -0,0 +1,136 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) for the keys Chirpy accepts.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms is offered to the browser in order of preference.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters, RFC 9052 section 7 and RFC 9053 section 7.
+const (
+	coseKeyType      = 1
+	coseKeyAlg       = 3
+	coseCurve        = -1
+	coseX            = -2
+	coseY            = -3
+	coseRSAN         = -1
+	coseRSAE         = -2
+	coseTypeOKP      = 1
+	coseTypeEC2      = 2
+	coseTypeRSA      = 3
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+const minRSABits = 2048
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported or malformed credential public key")
+	ErrBadSignature   = errors.New("signature verification failed")
+)
+
+// PublicKey is a credential public key parsed from its COSE encoding.
+type PublicKey struct {
+	Algorithm int64
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey parses a COSE_Key, as stored with a credential.
+func ParsePublicKey(data []byte) (PublicKey, error) {
+	v, err := decodeCBORAll(data)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return PublicKey{}, fmt.Errorf("%w: not a map", ErrUnsupportedKey)
+	}
+	return parseCOSEKey(m)
+}
+
+func parseCOSEKey(m map[interface{}]interface{}) (PublicKey, error) {
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseKeyAlg)].(int64)
+
+	switch {
+	case kty == coseTypeEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return PublicKey{}, fmt.Errorf("%w: bad P-256 key", ErrUnsupportedKey)
+		}
+		// crypto/ecdh checks that the point is on the curve.
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return PublicKey{}, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+		}
+		return PublicKey{Algorithm: alg, key: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}}, nil
+
+	case kty == coseTypeOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return PublicKey{}, fmt.Errorf("%w: bad Ed25519 key", ErrUnsupportedKey)
+		}
+		return PublicKey{Algorithm: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseTypeRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return PublicKey{}, fmt.Errorf("%w: bad RSA exponent", ErrUnsupportedKey)
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		modulus := new(big.Int).SetBytes(n)
+		if modulus.BitLen() < minRSABits || exponent < 3 || exponent%2 == 0 {
+			return PublicKey{}, fmt.Errorf("%w: weak RSA key", ErrUnsupportedKey)
+		}
+		return PublicKey{Algorithm: alg, key: &rsa.PublicKey{N: modulus, E: exponent}}, nil
+	}
+	return PublicKey{}, fmt.Errorf("%w: key type %d, algorithm %d", ErrUnsupportedKey, kty, alg)
+}
+
+// Verify checks sig over message with the key's algorithm.
+func (k PublicKey) Verify(message, sig []byte) error {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if ecdsa.VerifyASN1(key, digest[:], sig) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, message, sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	default:
+		return ErrUnsupportedKey
+	}
+	return ErrBadSignature
+}
@@ -0,0 +1,394 @@
+// Package webauthn verifies WebAuthn (passkey) registration and
+// authentication ceremonies for a single relying party. Only the "none"
+// attestation format is supported: Chirpy doesn't restrict which
+// authenticators people use, so it has no use for attestation certificates.
+// Challenge and credential storage live with the handlers.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// ChallengeSize is the length of a ceremony challenge in bytes.
+	ChallengeSize = 32
+	// maxCredentialIDLength is the limit from the WebAuthn spec.
+	maxCredentialIDLength = 1023
+)
+
+// Authenticator data flags, WebAuthn section 6.1.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+var (
+	ErrInvalidResponse        = errors.New("invalid WebAuthn response")
+	ErrUnsupportedAttestation = errors.New("only \"none\" attestation is supported")
+	// ErrSignCount means the authenticator's signature counter didn't go
+	// up, which suggests the credential's private key has been copied.
+	ErrSignCount = errors.New("signature counter did not increase")
+)
+
+// RelyingParty is the site credentials are scoped to. ID is a registrable
+// domain such as "chirpy.example" and Origins lists the exact origins, such
+// as "https://chirpy.example", that ceremonies may run on.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// NewChallenge returns a random challenge for one ceremony.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CredentialDescriptor identifies a credential to the browser.
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// CredentialParameter names a key algorithm the relying party accepts.
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CreationOptions is the JSON form of PublicKeyCredentialCreationOptions,
+// with binary fields in unpadded base64url, as taken by
+// PublicKeyCredential.parseCreationOptionsFromJSON.
+type CreationOptions struct {
+	RP struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions is the JSON form of PublicKeyCredentialRequestOptions.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	out := make([]CredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, CredentialDescriptor{Type: "public-key", ID: encode(id)})
+	}
+	return out
+}
+
+// CreationOptions returns the options for registering a passkey for the
+// user with the given handle. Passkeys are discoverable and verify the
+// user, so they can stand in for both the email and the password. exclude
+// lists the user's existing credentials so the same authenticator isn't
+// registered twice.
+func (rp RelyingParty) CreationOptions(challenge, userHandle []byte, userName string, exclude [][]byte, timeout time.Duration) CreationOptions {
+	var opts CreationOptions
+	opts.RP.ID = rp.ID
+	opts.RP.Name = rp.Name
+	opts.User.ID = encode(userHandle)
+	opts.User.Name = userName
+	opts.User.DisplayName = userName
+	opts.Challenge = encode(challenge)
+	for _, alg := range SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	opts.Timeout = timeout.Milliseconds()
+	opts.ExcludeCredentials = descriptors(exclude)
+	opts.AuthenticatorSelection.ResidentKey = "required"
+	opts.AuthenticatorSelection.RequireResidentKey = true
+	opts.AuthenticatorSelection.UserVerification = "required"
+	opts.Attestation = "none"
+	return opts
+}
+
+// RequestOptions returns the options for signing in. With no allowed
+// credentials the browser offers whichever passkeys it has for the site.
+func (rp RelyingParty) RequestOptions(challenge []byte, allow [][]byte, timeout time.Duration) RequestOptions {
+	return RequestOptions{
+		Challenge:        encode(challenge),
+		Timeout:          timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: "required",
+	}
+}
+
+// clientData is the part of CollectedClientData that is checked.
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: client data: %v", ErrInvalidResponse, err)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: client data type is %q, want %q", ErrInvalidResponse, cd.Type, ceremony)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidResponse)
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return fmt.Errorf("%w: unexpected origin %q", ErrInvalidResponse, cd.Origin)
+	}
+	if cd.CrossOrigin {
+		return fmt.Errorf("%w: cross-origin ceremony", ErrInvalidResponse)
+	}
+	return nil
+}
+
+// authenticatorData is parsed authenticator data, WebAuthn section 6.1.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+	ad := authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if ad.flags&flagAttested != 0 {
+		// AAGUID, then a two-byte length and the credential ID.
+		if len(rest) < 18 {
+			return authenticatorData{}, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > maxCredentialIDLength || len(rest) < idLen {
+			return authenticatorData{}, fmt.Errorf("%w: bad credential ID length", ErrInvalidResponse)
+		}
+		ad.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("%w: credential public key: %v", ErrInvalidResponse, err)
+		}
+		ad.publicKey = rest[:n]
+		rest = rest[n:]
+	}
+	if ad.flags&flagExtensions != 0 {
+		v, n, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("%w: extensions: %v", ErrInvalidResponse, err)
+		}
+		if _, ok := v.(map[interface{}]interface{}); !ok {
+			return authenticatorData{}, fmt.Errorf("%w: extensions are not a map", ErrInvalidResponse)
+		}
+		rest = rest[n:]
+	}
+	if len(rest) != 0 {
+		return authenticatorData{}, fmt.Errorf("%w: %d trailing bytes in authenticator data", ErrInvalidResponse, len(rest))
+	}
+	return ad, nil
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(ad authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(ad.rpIDHash, rpIDHash[:]) != 1 {
+		return fmt.Errorf("%w: relying party ID mismatch", ErrInvalidResponse)
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return fmt.Errorf("%w: user not present", ErrInvalidResponse)
+	}
+	if ad.flags&flagUserVerified == 0 {
+		return fmt.Errorf("%w: user not verified", ErrInvalidResponse)
+	}
+	return nil
+}
+
+// Base64URL is binary data that is base64url-encoded in JSON, as in the
+// output of PublicKeyCredential.toJSON(). Padding is accepted but not sent.
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encode(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Registration is the authenticator's response to navigator.credentials.create.
+type Registration struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AttestationObject Base64URL `json:"attestationObject"`
+}
+
+// RegistrationCredential is a new credential as the browser encodes it.
+type RegistrationCredential struct {
+	RawID    Base64URL    `json:"rawId"`
+	Type     string       `json:"type"`
+	Response Registration `json:"response"`
+}
+
+// Credential is a newly registered credential, to be stored with its user.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// issued for it and returns the new credential.
+func (rp RelyingParty) VerifyRegistration(challenge []byte, resp Registration) (Credential, error) {
+	if err := rp.verifyClientData(resp.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return Credential{}, err
+	}
+
+	v, err := decodeCBORAll(resp.AttestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: attestation object: %v", ErrInvalidResponse, err)
+	}
+	obj, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: attestation object is not a map", ErrInvalidResponse)
+	}
+	format, _ := obj["fmt"].(string)
+	attStmt, _ := obj["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := obj["authData"].([]byte)
+	if format != "none" || attStmt == nil || len(attStmt) != 0 {
+		return Credential{}, ErrUnsupportedAttestation
+	}
+
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := rp.verifyAuthenticatorData(ad); err != nil {
+		return Credential{}, err
+	}
+	if ad.flags&flagAttested == 0 {
+		return Credential{}, fmt.Errorf("%w: no attested credential data", ErrInvalidResponse)
+	}
+	key, err := ParsePublicKey(ad.publicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+	if !slices.Contains(SupportedAlgorithms, key.Algorithm) {
+		return Credential{}, ErrUnsupportedKey
+	}
+
+	return Credential{
+		ID:        bytes.Clone(ad.credentialID),
+		PublicKey: bytes.Clone(ad.publicKey),
+		SignCount: ad.signCount,
+	}, nil
+}
+
+// Assertion is the authenticator's response to navigator.credentials.get.
+type Assertion struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AuthenticatorData Base64URL `json:"authenticatorData"`
+	Signature         Base64URL `json:"signature"`
+	// UserHandle is the user's ID from CreationOptions. Authenticators
+	// always return it for discoverable credentials.
+	UserHandle Base64URL `json:"userHandle"`
+}
+
+// AssertionCredential is a login response as the browser encodes it.
+type AssertionCredential struct {
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response Assertion `json:"response"`
+}
+
+// VerifyAssertion checks an assertion made with a stored credential against
+// the challenge issued for it, and returns the credential's new signature
+// counter. It returns ErrSignCount, once the signature is known to be good,
+// if the counter suggests the credential has been cloned.
+func (rp RelyingParty) VerifyAssertion(challenge, publicKey []byte, storedSignCount uint32, resp Assertion) (uint32, error) {
+	if err := rp.verifyClientData(resp.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	ad, err := parseAuthenticatorData(resp.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(ad); err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(resp.ClientDataJSON)
+	signed := append(bytes.Clone(resp.AuthenticatorData), clientDataHash[:]...)
+	if err := key.Verify(signed, resp.Signature); err != nil {
+		return 0, err
+	}
+
+	// Authenticators that don't keep a counter always report zero.
+	if (ad.signCount != 0 || storedSignCount != 0) && ad.signCount <= storedSignCount {
+		return 0, ErrSignCount
+	}
+	return ad.signCount, nil
+}
@@ -0,0 +1,524 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+// encodeCBOR is a test-only CBOR encoder for the types the decoder returns.
+// Map keys are written in a fixed order so output is deterministic.
+func encodeCBOR(v interface{}) []byte {
+	var buf bytes.Buffer
+	writeCBOR(&buf, v)
+	return buf.Bytes()
+}
+
+func writeHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+func writeCBOR(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case int:
+		writeCBOR(buf, int64(v))
+	case int64:
+		if v >= 0 {
+			writeHead(buf, 0, uint64(v))
+		} else {
+			writeHead(buf, 1, uint64(-1-v))
+		}
+	case []byte:
+		writeHead(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		writeHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			writeCBOR(buf, item)
+		}
+	case map[interface{}]interface{}:
+		keys := make([]interface{}, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		writeHead(buf, 5, uint64(len(v)))
+		for _, k := range keys {
+			writeCBOR(buf, k)
+			writeCBOR(buf, v[k])
+		}
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case nil:
+		buf.WriteByte(0xf6)
+	default:
+		panic(fmt.Sprintf("encodeCBOR: unsupported type %T", v))
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "Small integer", input: []byte{0x0a}, want: int64(10)},
+		{name: "Two byte integer", input: []byte{0x19, 0x03, 0xe8}, want: int64(1000)},
+		{name: "Negative integer", input: []byte{0x38, 0x63}, want: int64(-100)},
+		{name: "Byte string", input: []byte{0x43, 1, 2, 3}, want: []byte{1, 2, 3}},
+		{name: "Text string", input: []byte{0x62, 'h', 'i'}, want: "hi"},
+		{name: "Array", input: []byte{0x82, 0x01, 0xf5}, want: []interface{}{int64(1), true}},
+		{name: "Map", input: []byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf6}, want: map[interface{}]interface{}{int64(1): int64(2), "a": nil}},
+		{name: "Trailing bytes", input: []byte{0x01, 0x02}, wantErr: true},
+		{name: "Truncated string", input: []byte{0x45, 1, 2}, wantErr: true},
+		{name: "Huge length", input: []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "Indefinite length", input: []byte{0x5f, 0x41, 0x00, 0xff}, wantErr: true},
+		{name: "Float", input: []byte{0xf9, 0x3c, 0x00}, wantErr: true},
+		{name: "Tag", input: []byte{0xc1, 0x00}, wantErr: true},
+		{name: "Invalid UTF-8", input: []byte{0x61, 0xff}, wantErr: true},
+		{name: "Duplicate map key", input: []byte{0xa2, 0x01, 0x00, 0x01, 0x00}, wantErr: true},
+		{name: "Array map key", input: []byte{0xa1, 0x80, 0x00}, wantErr: true},
+		{name: "Integer overflow", input: []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "Too deep", input: bytes.Repeat([]byte{0x81}, maxCBORDepth+2), wantErr: true},
+		{name: "Empty", input: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCBORAll(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeCBORAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidCBOR) {
+					t.Errorf("error %v is not ErrInvalidCBOR", err)
+				}
+				return
+			}
+			if !bytes.Equal(encodeCBOR(got), encodeCBOR(tt.want)) {
+				t.Errorf("decodeCBORAll() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// authenticator is a software authenticator holding one credential.
+type authenticator struct {
+	credentialID []byte
+	signer       crypto.Signer
+	cose         map[interface{}]interface{}
+	hash         bool
+	signCount    uint32
+	flags        byte
+}
+
+func newAuthenticator(t *testing.T, alg int64) *authenticator {
+	t.Helper()
+	a := &authenticator{
+		credentialID: make([]byte, 16),
+		flags:        flagUserPresent | flagUserVerified,
+	}
+	rand.Read(a.credentialID)
+
+	switch alg {
+	case AlgES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.signer, a.hash = key, true
+		a.cose = map[interface{}]interface{}{
+			int64(coseKeyType): int64(coseTypeEC2),
+			int64(coseKeyAlg):  int64(AlgES256),
+			int64(coseCurve):   int64(coseCurveP256),
+			int64(coseX):       key.X.FillBytes(make([]byte, 32)),
+			int64(coseY):       key.Y.FillBytes(make([]byte, 32)),
+		}
+	case AlgEdDSA:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.signer = key
+		a.cose = map[interface{}]interface{}{
+			int64(coseKeyType): int64(coseTypeOKP),
+			int64(coseKeyAlg):  int64(AlgEdDSA),
+			int64(coseCurve):   int64(coseCurveEd25519),
+			int64(coseX):       []byte(pub),
+		}
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.signer, a.hash = key, true
+		a.cose = map[interface{}]interface{}{
+			int64(coseKeyType): int64(coseTypeRSA),
+			int64(coseKeyAlg):  int64(AlgRS256),
+			int64(coseRSAN):    key.N.Bytes(),
+			int64(coseRSAE):    big.NewInt(int64(key.E)).Bytes(),
+		}
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	return a
+}
+
+func clientDataJSON(ceremony string, challenge []byte, origin string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	return b
+}
+
+func (a *authenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	flags := a.flags
+	if attested {
+		flags |= flagAttested
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, encodeCBOR(a.cose)...)
+	}
+	return data
+}
+
+func (a *authenticator) create(rpID, origin string, challenge []byte) Registration {
+	return Registration{
+		ClientDataJSON: clientDataJSON("webauthn.create", challenge, origin),
+		AttestationObject: encodeCBOR(map[interface{}]interface{}{
+			"fmt":      "none",
+			"attStmt":  map[interface{}]interface{}{},
+			"authData": a.authData(rpID, true),
+		}),
+	}
+}
+
+func (a *authenticator) get(t *testing.T, rpID, origin string, challenge []byte) Assertion {
+	t.Helper()
+	a.signCount++
+	cd := clientDataJSON("webauthn.get", challenge, origin)
+	authData := a.authData(rpID, false)
+	cdHash := sha256.Sum256(cd)
+	message := append(bytes.Clone(authData), cdHash[:]...)
+
+	var sig []byte
+	var err error
+	if a.hash {
+		digest := sha256.Sum256(message)
+		sig, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	} else {
+		sig, err = a.signer.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Assertion{
+		ClientDataJSON:    cd,
+		AuthenticatorData: authData,
+		Signature:         sig,
+	}
+}
+
+var testRP = RelyingParty{ID: "chirpy.example", Name: "Chirpy", Origins: []string{"https://chirpy.example"}}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	for _, alg := range SupportedAlgorithms {
+		t.Run(fmt.Sprintf("Algorithm %d", alg), func(t *testing.T) {
+			a := newAuthenticator(t, alg)
+			challenge, err := NewChallenge()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cred, err := testRP.VerifyRegistration(challenge, a.create(testRP.ID, testRP.Origins[0], challenge))
+			if err != nil {
+				t.Fatalf("VerifyRegistration() error = %v", err)
+			}
+			if !bytes.Equal(cred.ID, a.credentialID) {
+				t.Errorf("credential ID = %x, want %x", cred.ID, a.credentialID)
+			}
+
+			signCount := cred.SignCount
+			for i := 0; i < 2; i++ {
+				challenge, _ = NewChallenge()
+				signCount, err = testRP.VerifyAssertion(challenge, cred.PublicKey, signCount, a.get(t, testRP.ID, testRP.Origins[0], challenge))
+				if err != nil {
+					t.Fatalf("VerifyAssertion() error = %v", err)
+				}
+				if signCount != a.signCount {
+					t.Errorf("sign count = %d, want %d", signCount, a.signCount)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationErrors(t *testing.T) {
+	challenge, _ := NewChallenge()
+	otherChallenge, _ := NewChallenge()
+	tests := []struct {
+		name    string
+		modify  func(a *authenticator) Registration
+		wantErr error
+	}{
+		{
+			name:    "Wrong challenge",
+			modify:  func(a *authenticator) Registration { return a.create(testRP.ID, testRP.Origins[0], otherChallenge) },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "Wrong origin",
+			modify:  func(a *authenticator) Registration { return a.create(testRP.ID, "https://evil.example", challenge) },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "Wrong relying party ID",
+			modify:  func(a *authenticator) Registration { return a.create("evil.example", testRP.Origins[0], challenge) },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "Wrong ceremony type",
+			modify: func(a *authenticator) Registration {
+				reg := a.create(testRP.ID, testRP.Origins[0], challenge)
+				reg.ClientDataJSON = clientDataJSON("webauthn.get", challenge, testRP.Origins[0])
+				return reg
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "User not verified",
+			modify: func(a *authenticator) Registration {
+				a.flags = flagUserPresent
+				return a.create(testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "Packed attestation",
+			modify: func(a *authenticator) Registration {
+				reg := a.create(testRP.ID, testRP.Origins[0], challenge)
+				reg.AttestationObject = encodeCBOR(map[interface{}]interface{}{
+					"fmt":      "packed",
+					"attStmt":  map[interface{}]interface{}{"alg": int64(AlgES256), "sig": []byte{1}},
+					"authData": a.authData(testRP.ID, true),
+				})
+				return reg
+			},
+			wantErr: ErrUnsupportedAttestation,
+		},
+		{
+			name: "Truncated authenticator data",
+			modify: func(a *authenticator) Registration {
+				reg := a.create(testRP.ID, testRP.Origins[0], challenge)
+				authData := a.authData(testRP.ID, true)
+				reg.AttestationObject = encodeCBOR(map[interface{}]interface{}{
+					"fmt":      "none",
+					"attStmt":  map[interface{}]interface{}{},
+					"authData": authData[:len(authData)-1],
+				})
+				return reg
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "Unsupported key",
+			modify: func(a *authenticator) Registration {
+				a.cose[int64(coseKeyAlg)] = int64(-35)
+				return a.create(testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: ErrUnsupportedKey,
+		},
+		{
+			name: "Point not on curve",
+			modify: func(a *authenticator) Registration {
+				a.cose[int64(coseY)] = bytes.Repeat([]byte{1}, 32)
+				return a.create(testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: ErrUnsupportedKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t, AlgES256)
+			_, err := testRP.VerifyRegistration(challenge, tt.modify(a))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyRegistration() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionErrors(t *testing.T) {
+	challenge, _ := NewChallenge()
+	otherChallenge, _ := NewChallenge()
+	tests := []struct {
+		name            string
+		storedSignCount uint32
+		modify          func(t *testing.T, a *authenticator) Assertion
+		wantErr         error
+	}{
+		{
+			name: "Wrong challenge",
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				return a.get(t, testRP.ID, testRP.Origins[0], otherChallenge)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "Wrong origin",
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				return a.get(t, testRP.ID, "http://chirpy.example", challenge)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "User not present",
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				a.flags = flagUserVerified
+				return a.get(t, testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "Tampered authenticator data",
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				assertion := a.get(t, testRP.ID, testRP.Origins[0], challenge)
+				assertion.AuthenticatorData[36]++
+				return assertion
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name: "Signed by another key",
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				a.signer = newAuthenticator(t, AlgES256).signer
+				return a.get(t, testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name:            "Sign count went backwards",
+			storedSignCount: 10,
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				return a.get(t, testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: ErrSignCount,
+		},
+		{
+			name:            "Sign count repeated",
+			storedSignCount: 1,
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				return a.get(t, testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: ErrSignCount,
+		},
+		{
+			name: "Authenticator without a counter",
+			modify: func(t *testing.T, a *authenticator) Assertion {
+				a.signCount = ^uint32(0)
+				return a.get(t, testRP.ID, testRP.Origins[0], challenge)
+			},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t, AlgES256)
+			publicKey := encodeCBOR(a.cose)
+			_, err := testRP.VerifyAssertion(challenge, publicKey, tt.storedSignCount, tt.modify(t, a))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyAssertion() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreationOptions(t *testing.T) {
+	challenge := bytes.Repeat([]byte{0xab}, ChallengeSize)
+	opts := testRP.CreationOptions(challenge, []byte{1, 2, 3}, "walt@example.com", [][]byte{{9}}, 0)
+	if opts.RP.ID != testRP.ID || opts.User.ID != "AQID" || opts.Challenge != base64.RawURLEncoding.EncodeToString(challenge) {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	if len(opts.PubKeyCredParams) != len(SupportedAlgorithms) || opts.PubKeyCredParams[0].Alg != AlgES256 {
+		t.Errorf("pubKeyCredParams = %+v", opts.PubKeyCredParams)
+	}
+	if len(opts.ExcludeCredentials) != 1 || opts.ExcludeCredentials[0].ID != "CQ" {
+		t.Errorf("excludeCredentials = %+v", opts.ExcludeCredentials)
+	}
+	if opts.AuthenticatorSelection.UserVerification != "required" || opts.Attestation != "none" {
+		t.Errorf("authenticatorSelection = %+v, attestation = %s", opts.AuthenticatorSelection, opts.Attestation)
+	}
+}
+
+func TestAssertionCredentialJSON(t *testing.T) {
+	// The shape PublicKeyCredential.toJSON() produces, with one padded field.
+	input := `{
+		"id": "AQID",
+		"rawId": "AQID",
+		"type": "public-key",
+		"response": {
+			"clientDataJSON": "e30",
+			"authenticatorData": "BAU=",
+			"signature": "Bg",
+			"userHandle": "-_8"
+		},
+		"clientExtensionResults": {}
+	}`
+	var cred AssertionCredential
+	if err := json.Unmarshal([]byte(input), &cred); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !bytes.Equal(cred.RawID, []byte{1, 2, 3}) ||
+		string(cred.Response.ClientDataJSON) != "{}" ||
+		!bytes.Equal(cred.Response.AuthenticatorData, []byte{4, 5}) ||
+		!bytes.Equal(cred.Response.Signature, []byte{6}) ||
+		!bytes.Equal(cred.Response.UserHandle, []byte{0xfb, 0xff}) {
+		t.Errorf("unexpected credential %+v", cred)
+	}
+
+	if err := json.Unmarshal([]byte(`{"rawId": "not base64!"}`), &cred); err == nil {
+		t.Error("Unmarshal() accepted invalid base64url")
+	}
+}
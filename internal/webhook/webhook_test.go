@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	now := time.Unix(1700000000, 0)
+	valid := Sign(secret, now, body)
+	_, validSig, _ := strings.Cut(valid, ",")
+
+	tests := []struct {
+		name         string
+		header       string
+		body         []byte
+		wantSignedAt time.Time
+		wantErr      error
+	}{
+		{name: "Valid", header: valid, body: body, wantSignedAt: now},
+		{name: "Valid with spaces", header: strings.ReplaceAll(valid, ",", ", "), body: body},
+		{name: "Rotated secret", header: valid + ",v1=" + strings.Repeat("ab", 32), body: body},
+		{name: "Unknown scheme ignored", header: valid + ",v0=legacy", body: body},
+		{name: "Slightly old", header: Sign(secret, now.Add(-DefaultTolerance+time.Second), body), body: body, wantSignedAt: now.Add(-DefaultTolerance + time.Second)},
+		{name: "Slightly ahead", header: Sign(secret, now.Add(DefaultTolerance-time.Second), body), body: body},
+		{name: "Missing", header: "", body: body, wantErr: ErrNoSignature},
+		{name: "No timestamp", header: validSig, body: body, wantErr: ErrMalformedSignature},
+		{name: "No signature", header: fmt.Sprintf("t=%d", now.Unix()), body: body, wantErr: ErrMalformedSignature},
+		{name: "Two timestamps", header: valid + ",t=1", body: body, wantErr: ErrMalformedSignature},
+		{name: "Bad hex", header: fmt.Sprintf("t=%d,v1=zz", now.Unix()), body: body, wantErr: ErrMalformedSignature},
+		{name: "Garbage", header: "nonsense", body: body, wantErr: ErrMalformedSignature},
+		{name: "Too old", header: Sign(secret, now.Add(-DefaultTolerance-time.Second), body), body: body, wantErr: ErrStaleSignature},
+		{name: "Too far ahead", header: Sign(secret, now.Add(DefaultTolerance+time.Second), body), body: body, wantErr: ErrStaleSignature},
+		{name: "Tampered body", header: valid, body: []byte(strings.Replace(string(body), "evt_1", "evt_2", 1)), wantErr: ErrSignatureMismatch},
+		{name: "Wrong secret", header: Sign([]byte("other"), now, body), body: body, wantErr: ErrSignatureMismatch},
+		{name: "Timestamp swapped", header: fmt.Sprintf("t=%d,%s", now.Unix()+1, validSig), body: body, wantErr: ErrSignatureMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedAt, err := Verify(secret, tt.header, tt.body, now, DefaultTolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if !tt.wantSignedAt.IsZero() && !signedAt.Equal(tt.wantSignedAt) {
+				t.Errorf("Verify() signed at %v, want %v", signedAt, tt.wantSignedAt)
+			}
+		})
+	}
+}
@@ -11,8 +11,27 @@ import (
 	"github.com/samuelhamann/chirpy/internal/database"
 	"fmt"
 	"strings"
+	"time"
+	"context"
+	"github.com/samuelhamann/chirpy/internal/trending"
+	"github.com/samuelhamann/chirpy/internal/blobstore"
+	"github.com/samuelhamann/chirpy/internal/auth"
+	"github.com/samuelhamann/chirpy/internal/mailer"
+	"github.com/samuelhamann/chirpy/internal/webauthn"
+	"github.com/samuelhamann/chirpy/internal/password"
+	"github.com/samuelhamann/chirpy/internal/subscription"
+	"github.com/samuelhamann/chirpy/internal/entitlement"
+	"github.com/samuelhamann/chirpy/internal/media"
+	"net/url"
+	"strconv"
+	"github.com/google/uuid"
+	"path/filepath"
 )
 
+// staticDir is the only directory served under /app/. Mail and media must
+// live outside it, otherwise their files could be fetched without a login.
+const staticDir = "static"
+
 func main() {
 
 	godotenv.Load()
@@ -24,9 +43,20 @@ func main() {
 	}
 	defer db.Close()
 
+	// Access tokens are signed with the keys in JWT_KEYS_DIR when it is set,
+	// and with the shared HS256 JWT_SECRET otherwise.
 	JWTSecret := os.Getenv("JWT_SECRET")
-	if len(JWTSecret) == 0 {
-		fmt.Println("JWT_SECRET is not set")
+	var keys *auth.Keyring
+	if keysDir := os.Getenv("JWT_KEYS_DIR"); len(keysDir) > 0 {
+		keys, err = auth.LoadKeyring(keysDir, os.Getenv("JWT_SIGNING_KID"))
+		if err != nil {
+			fmt.Println("JWT_KEYS_DIR is not usable:", err)
+			os.Exit(1)
+		}
+	} else if len(JWTSecret) > 0 {
+		keys = auth.NewHMACKeyring(JWTSecret)
+	} else {
+		fmt.Println("JWT_SECRET or JWT_KEYS_DIR must be set")
 		os.Exit(1)
 	}
 	PolkaKey:= os.Getenv("POLKA_KEY")
@@ -34,33 +64,354 @@ func main() {
 		fmt.Println("POLKA_KEY is not set")
 		os.Exit(1)
 	}
+	// CHIRP_EDIT_WINDOW applies to every tier; ENTITLEMENTS_FILE can then
+	// change any limit of any tier.
+	entitlements := entitlement.DefaultTable()
+	if raw := os.Getenv("CHIRP_EDIT_WINDOW"); len(raw) > 0 {
+		chirpEditWindow, err := time.ParseDuration(raw)
+		if err != nil || chirpEditWindow < 0 {
+			fmt.Println("CHIRP_EDIT_WINDOW must be a duration such as 15m")
+			os.Exit(1)
+		}
+		for tier, e := range entitlements {
+			e.EditWindow = entitlement.Duration(chirpEditWindow)
+			entitlements[tier] = e
+		}
+	}
+	if path := os.Getenv("ENTITLEMENTS_FILE"); len(path) > 0 {
+		entitlements, err = entitlement.LoadTable(path, entitlements)
+		if err != nil {
+			fmt.Println("ENTITLEMENTS_FILE is not usable:", err)
+			os.Exit(1)
+		}
+	}
+	trendingCfg := trending.DefaultConfig()
+	for _, setting := range []struct {
+		env string
+		dst *time.Duration
+	}{{"TRENDING_WINDOW", &trendingCfg.Window}, {"TRENDING_BASELINE", &trendingCfg.Baseline}} {
+		raw := os.Getenv(setting.env)
+		if len(raw) == 0 {
+			continue
+		}
+		*setting.dst, err = time.ParseDuration(raw)
+		if err != nil || *setting.dst <= 0 {
+			fmt.Println(setting.env, "must be a duration such as 1h")
+			os.Exit(1)
+		}
+	}
+	if trendingCfg.Baseline <= trendingCfg.Window {
+		fmt.Println("TRENDING_BASELINE must be longer than TRENDING_WINDOW")
+		os.Exit(1)
+	}
+	trendingCfg.HalfLife = trendingCfg.Window / 2
+
+	var mediaStore blobstore.BlobStore
+	switch os.Getenv("MEDIA_STORE") {
+	case "", "local":
+		mediaDir := os.Getenv("MEDIA_DIR")
+		if len(mediaDir) == 0 {
+			mediaDir = "media"
+		}
+		if insideDir(mediaDir, staticDir) {
+			fmt.Println("MEDIA_DIR must not be inside", staticDir)
+			os.Exit(1)
+		}
+		mediaStore, err = blobstore.NewLocalStore(mediaDir)
+		if err != nil {
+			fmt.Println("MEDIA_DIR is not usable:", err)
+			os.Exit(1)
+		}
+	case "s3":
+		s3Store := &blobstore.S3Store{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}
+		if len(s3Store.Region) == 0 {
+			s3Store.Region = "us-east-1"
+		}
+		if len(s3Store.Endpoint) == 0 || len(s3Store.Bucket) == 0 || len(s3Store.AccessKeyID) == 0 || len(s3Store.SecretAccessKey) == 0 {
+			fmt.Println("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set when MEDIA_STORE=s3")
+			os.Exit(1)
+		}
+		mediaStore = s3Store
+	default:
+		fmt.Println("MEDIA_STORE must be local or s3")
+		os.Exit(1)
+	}
+	mediaMaxBytes := int64(5 << 20)
+	if raw := os.Getenv("MEDIA_MAX_BYTES"); len(raw) > 0 {
+		mediaMaxBytes, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || mediaMaxBytes <= 0 {
+			fmt.Println("MEDIA_MAX_BYTES must be a positive number of bytes")
+			os.Exit(1)
+		}
+	}
+	// Uploads that are never attached to a chirp or used as an avatar are
+	// deleted once they are MEDIA_ORPHAN_TTL old.
+	mediaOrphanTTL := 24 * time.Hour
+	if raw := os.Getenv("MEDIA_ORPHAN_TTL"); len(raw) > 0 {
+		mediaOrphanTTL, err = time.ParseDuration(raw)
+		if err != nil || mediaOrphanTTL <= 0 {
+			fmt.Println("MEDIA_ORPHAN_TTL must be a duration such as 24h")
+			os.Exit(1)
+		}
+	}
+	// Media URLs are signed with MEDIA_SIGNING_KEY, or failing that with a
+	// key derived from JWT_SECRET; never with the JWT secret itself.
+	mediaSigningKey := []byte(os.Getenv("MEDIA_SIGNING_KEY"))
+	if len(mediaSigningKey) == 0 && len(JWTSecret) > 0 {
+		mediaSigningKey, err = media.DeriveSigningKey([]byte(JWTSecret))
+		if err != nil {
+			fmt.Println("Could not derive a media signing key:", err)
+			os.Exit(1)
+		}
+	}
+	if len(mediaSigningKey) == 0 {
+		fmt.Println("MEDIA_SIGNING_KEY must be set when JWT_SECRET is not")
+		os.Exit(1)
+	}
+	// Raising the hashing cost only applies to new hashes; existing ones
+	// are upgraded as their users log in.
+	passwordParams := auth.DefaultPasswordParams
+	for _, setting := range []struct {
+		env string
+		dst *uint32
+	}{{"PASSWORD_HASH_MEMORY_KIB", &passwordParams.Memory}, {"PASSWORD_HASH_ITERATIONS", &passwordParams.Iterations}} {
+		raw := os.Getenv(setting.env)
+		if len(raw) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || n == 0 {
+			fmt.Println(setting.env, "must be a positive number")
+			os.Exit(1)
+		}
+		*setting.dst = uint32(n)
+	}
+	if raw := os.Getenv("PASSWORD_HASH_PARALLELISM"); len(raw) > 0 {
+		n, err := strconv.ParseUint(raw, 10, 8)
+		if err != nil || n == 0 {
+			fmt.Println("PASSWORD_HASH_PARALLELISM must be a number from 1 to 255")
+			os.Exit(1)
+		}
+		passwordParams.Parallelism = uint8(n)
+	}
+	if passwordParams.Memory < 8*uint32(passwordParams.Parallelism) {
+		fmt.Println("PASSWORD_HASH_MEMORY_KIB must be at least 8 per thread of PASSWORD_HASH_PARALLELISM")
+		os.Exit(1)
+	}
+	passwordPolicy := password.DefaultPolicy
+	if raw := os.Getenv("PASSWORD_MIN_LENGTH"); len(raw) > 0 {
+		passwordPolicy.MinLength, err = strconv.Atoi(raw)
+		if err != nil || passwordPolicy.MinLength < 1 || passwordPolicy.MinLength > passwordPolicy.MaxLength {
+			fmt.Println("PASSWORD_MIN_LENGTH must be a number from 1 to", passwordPolicy.MaxLength)
+			os.Exit(1)
+		}
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); len(path) > 0 {
+		breached, err := password.LoadBreachList(path)
+		if err != nil {
+			fmt.Println("BREACHED_PASSWORDS_FILE is not usable:", err)
+			os.Exit(1)
+		}
+		passwordPolicy.Breached = breached
+	}
+	var mail mailer.Mailer
+	switch os.Getenv("MAILER") {
+	case "", "file":
+		mailDir := os.Getenv("MAIL_DIR")
+		if len(mailDir) == 0 {
+			mailDir = filepath.Join(os.TempDir(), "chirpy-mail")
+		}
+		if insideDir(mailDir, staticDir) {
+			fmt.Println("MAIL_DIR must not be inside", staticDir)
+			os.Exit(1)
+		}
+		mail, err = mailer.NewFileMailer(mailDir)
+		if err != nil {
+			fmt.Println("MAIL_DIR is not usable:", err)
+			os.Exit(1)
+		}
+	case "smtp":
+		smtpMailer := &mailer.SMTPMailer{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+		if len(smtpMailer.Addr) == 0 {
+			fmt.Println("SMTP_ADDR must be set when MAILER=smtp")
+			os.Exit(1)
+		}
+		mail = smtpMailer
+	default:
+		fmt.Println("MAILER must be file or smtp")
+		os.Exit(1)
+	}
+	mailFrom := os.Getenv("MAIL_FROM")
+	if len(mailFrom) == 0 {
+		mailFrom = "Chirpy <noreply@localhost>"
+	}
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if len(publicURL) == 0 {
+		publicURL = "http://localhost:8080"
+	}
+	requireVerifiedEmail := os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+	// Passkeys are bound to the relying party ID, so changing it later
+	// strands every passkey registered under the old one.
+	relyingParty := webauthn.RelyingParty{
+		ID:   os.Getenv("WEBAUTHN_RP_ID"),
+		Name: os.Getenv("WEBAUTHN_RP_NAME"),
+	}
+	if len(relyingParty.ID) == 0 {
+		parsed, err := url.Parse(publicURL)
+		if err != nil || len(parsed.Hostname()) == 0 {
+			fmt.Println("WEBAUTHN_RP_ID must be set when PUBLIC_URL has no host")
+			os.Exit(1)
+		}
+		relyingParty.ID = parsed.Hostname()
+	}
+	if len(relyingParty.Name) == 0 {
+		relyingParty.Name = "Chirpy"
+	}
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); len(origin) > 0 {
+			relyingParty.Origins = append(relyingParty.Origins, origin)
+		}
+	}
+	if len(relyingParty.Origins) == 0 {
+		relyingParty.Origins = []string{publicURL}
+	}
+	// OAUTH_RESOURCE_SERVERS lists the client IDs allowed to introspect
+	// tokens issued to other clients.
+	var resourceServers []uuid.UUID
+	for _, raw := range strings.Split(os.Getenv("OAUTH_RESOURCE_SERVERS"), ",") {
+		if raw = strings.TrimSpace(raw); len(raw) == 0 {
+			continue
+		}
+		clientID, err := uuid.Parse(raw)
+		if err != nil {
+			fmt.Println("OAUTH_RESOURCE_SERVERS must be a comma-separated list of client IDs")
+			os.Exit(1)
+		}
+		resourceServers = append(resourceServers, clientID)
+	}
 	platform := os.Getenv("PLATFORM")
 	fmt.Println("Starting Chirpy on platform:", platform)
 
 	dbQueries := database.New(db)
+	trends := trending.NewTracker(trendingCfg, dbQueries)
+	go trends.Run(context.Background())
+	go subscription.NewSweeper(dbQueries, time.Minute).Run(context.Background())
+
 	cfg := api.ApiConfig{
 		FileserverHits: atomic.Int32{},
+		DB: db,
 		Database: dbQueries,
 		Platform: strings.ToUpper(platform),
-		JWTSecret: JWTSecret,
+		Keys: keys,
 		PolkaKey: PolkaKey,
+		AdminKey: os.Getenv("ADMIN_API_KEY"),
+		Entitlements: entitlements,
+		Trending: trends,
+		Media: mediaStore,
+		MediaSigningKey: mediaSigningKey,
+		MediaMaxBytes: mediaMaxBytes,
+		Mailer: mail,
+		MailFrom: mailFrom,
+		PublicURL: publicURL,
+		RequireVerifiedEmail: requireVerifiedEmail,
+		WebAuthn: relyingParty,
+		Passwords: auth.NewPasswordHasher(passwordParams),
+		PasswordPolicy: passwordPolicy,
+		ResourceServers: resourceServers,
 	}
+	go cfg.RunScheduledChirps(context.Background(), time.Minute)
+	go cfg.RunMediaSweep(context.Background(), time.Hour, mediaOrphanTTL)
+
 	mux := http.NewServeMux()
-	mux.Handle("/app/", cfg.MiddlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
+	mux.Handle("/app/", cfg.MiddlewareMetricsInc(api.MiddlewareNoFraming(http.StripPrefix("/app", http.FileServer(http.Dir(staticDir))))))
 	mux.HandleFunc("GET /admin/metrics", cfg.HandlerMetrics)
 	mux.HandleFunc("POST /admin/reset", cfg.HandlerReset)
+	mux.HandleFunc("POST /admin/lockouts/clear", cfg.ClearLoginLockout)
+	mux.HandleFunc("GET /admin/webhooks/events", cfg.GetWebhookEvents)
 	mux.HandleFunc("POST /api/validate_chirp", cfg.ValidateChirp)
 	mux.HandleFunc("GET /api/healthz", handlerFunc)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.GetJWKS)
 	mux.HandleFunc("POST /api/users", cfg.CreateUser)
 	mux.HandleFunc("POST /api/login", cfg.LoginUser)
+	mux.HandleFunc("POST /api/login/mfa", cfg.LoginMFA)
+	mux.HandleFunc("POST /api/login/passkey/begin", cfg.BeginPasskeyLogin)
+	mux.HandleFunc("POST /api/login/passkey/finish", cfg.FinishPasskeyLogin)
+	mux.HandleFunc("POST /api/password-reset/request", cfg.RequestPasswordReset)
+	mux.HandleFunc("POST /api/password-reset/confirm", cfg.ConfirmPasswordReset)
+	mux.HandleFunc("POST /api/verify-email", cfg.VerifyEmail)
+	mux.HandleFunc("POST /api/verify-email/request", cfg.ResendVerificationEmail)
+	mux.HandleFunc("POST /api/mfa/totp", cfg.EnrollTOTP)
+	mux.HandleFunc("POST /api/mfa/totp/confirm", cfg.ConfirmTOTP)
+	mux.HandleFunc("DELETE /api/mfa/totp", cfg.DisableTOTP)
+	mux.HandleFunc("POST /api/mfa/recovery-codes", cfg.RegenerateRecoveryCodes)
+	mux.HandleFunc("POST /api/passkeys/register/begin", cfg.BeginPasskeyRegistration)
+	mux.HandleFunc("POST /api/passkeys/register/finish", cfg.FinishPasskeyRegistration)
+	mux.HandleFunc("GET /api/passkeys", cfg.GetPasskeys)
+	mux.HandleFunc("DELETE /api/passkeys/{credentialID}", cfg.DeletePasskey)
 	mux.HandleFunc("POST /api/chirps", cfg.CreateChirp)
 	mux.HandleFunc("GET /api/chirps", cfg.GetChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.GetChirpByID)
+	mux.HandleFunc("GET /api/scheduled-chirps", cfg.GetScheduledChirps)
+	mux.HandleFunc("DELETE /api/scheduled-chirps/{scheduledID}", cfg.DeleteScheduledChirp)
+	mux.HandleFunc("GET /api/entitlements", cfg.GetEntitlements)
 	mux.HandleFunc("POST /api/refresh", cfg.RefreshToken)
 	mux.HandleFunc("POST /api/revoke", cfg.RevokeToken)
+	mux.HandleFunc("GET /api/sessions", cfg.GetSessions)
+	mux.HandleFunc("PATCH /api/sessions/{sessionID}", cfg.RenameSession)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.RevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke-all", cfg.RevokeAllSessions)
+	mux.HandleFunc("POST /api/tokens", cfg.CreateAPIToken)
+	mux.HandleFunc("GET /api/tokens", cfg.GetAPITokens)
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.RevokeAPIToken)
+	mux.HandleFunc("POST /api/oauth/clients", cfg.CreateOAuthClient)
+	mux.HandleFunc("GET /api/oauth/clients", cfg.GetOAuthClients)
+	mux.HandleFunc("GET /api/oauth/clients/{clientID}", cfg.GetOAuthClient)
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", cfg.DeleteOAuthClient)
+	mux.HandleFunc("GET /oauth/authorize", cfg.Authorize)
+	mux.HandleFunc("POST /oauth/authorize", cfg.ApproveAuthorization)
+	mux.HandleFunc("POST /oauth/token", cfg.Token)
+	mux.HandleFunc("POST /oauth/revoke", cfg.RevokeOAuthToken)
+	mux.HandleFunc("POST /oauth/introspect", cfg.IntrospectToken)
 	mux.HandleFunc("PUT /api/users", cfg.UpdateUser)
+	mux.HandleFunc("GET /api/users/{handleOrID}", cfg.GetUserProfile)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.DeleteChirp)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.HandlePolkaWebhook)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.FollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.UnfollowUser)
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.GetFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.GetFollowing)
+	mux.HandleFunc("POST /api/users/{userID}/block", cfg.BlockUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", cfg.UnblockUser)
+	mux.HandleFunc("POST /api/users/{userID}/mute", cfg.MuteUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", cfg.UnmuteUser)
+	mux.HandleFunc("GET /api/blocks", cfg.GetBlocks)
+	mux.HandleFunc("GET /api/mutes", cfg.GetMutes)
+	mux.HandleFunc("GET /api/timeline", cfg.GetTimeline)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.GetChirpThread)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.UpdateChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.GetChirpRevisions)
+	mux.HandleFunc("GET /api/search", cfg.Search)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.GetHashtagChirps)
+	mux.HandleFunc("GET /api/users/{userID}/mentions", cfg.GetUserMentions)
+	mux.HandleFunc("GET /api/trending", cfg.GetTrending)
+	mux.HandleFunc("POST /api/media", cfg.UploadMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}", cfg.GetMedia)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.LikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.UnlikeChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/likes", cfg.GetChirpLikes)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirps", cfg.RechirpChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirps", cfg.UndoRechirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/rechirps", cfg.GetChirpRechirps)
 
 	server := &http.Server{
 		Addr:    ":8080",
@@ -69,6 +420,23 @@ func main() {
 	server.ListenAndServe()
 }
 
+// insideDir reports whether dir is root or somewhere below it.
+func insideDir(dir, root string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(absRoot, absDir)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
 func handlerFunc(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,43 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, user_id, name, token_hash, token_prefix, scopes, expires_at)
+VALUES (
+    sqlc.arg('id'),
+    sqlc.arg('user_id'),
+    sqlc.arg('name'),
+    sqlc.arg('token_hash'),
+    sqlc.arg('token_prefix'),
+    sqlc.arg('scopes')::text[],
+    sqlc.narg('expires_at')
+)
+RETURNING *;
+
+-- name: GetActiveAPIToken :one
+SELECT * FROM api_tokens
+WHERE token_hash = sqlc.arg('token_hash')
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = sqlc.arg('id')
+  AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: ListAPITokens :many
+SELECT * FROM api_tokens
+WHERE user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: RevokeAPIToken :execrows
+UPDATE api_tokens
+SET revoked_at = NOW()
+WHERE id = sqlc.arg('id')
+  AND user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserAPITokens :execrows
+UPDATE api_tokens
+SET revoked_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL;
@@ -0,0 +1,48 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, user_id, storage_key, content_type, size_bytes, width, height, created_at)
+VALUES (
+    $1, $2, $3, $4, $5, $6, $7, NOW()
+)
+RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments WHERE id = $1;
+
+-- name: AttachToChirp :many
+UPDATE attachments
+SET chirp_id = sqlc.arg('chirp_id'),
+    position = array_position(sqlc.arg('ids')::uuid[], id)
+WHERE id = ANY(sqlc.arg('ids')::uuid[])
+  AND user_id = sqlc.arg('user_id')
+  AND chirp_id IS NULL
+RETURNING id;
+
+-- name: ListAttachmentsForChirps :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: DeleteChirpAttachments :many
+DELETE FROM attachments
+WHERE chirp_id = $1
+RETURNING storage_key;
+
+-- name: CountAttachableMedia :one
+SELECT COUNT(*) FROM attachments
+WHERE id = ANY(sqlc.arg('ids')::uuid[])
+  AND user_id = sqlc.arg('user_id')
+  AND chirp_id IS NULL;
+
+-- name: DeleteOrphanedAttachments :many
+DELETE FROM attachments
+WHERE chirp_id IS NULL
+  AND created_at < sqlc.arg('created_before')
+  AND NOT EXISTS (
+      SELECT 1 FROM users WHERE users.avatar_attachment_id = attachments.id
+  )
+  AND NOT EXISTS (
+      SELECT 1 FROM scheduled_chirps
+      WHERE attachments.id = ANY(scheduled_chirps.attachment_ids)
+        AND scheduled_chirps.failure = ''
+  )
+RETURNING storage_key;
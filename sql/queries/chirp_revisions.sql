@@ -0,0 +1,28 @@
+-- name: EditChirp :one
+WITH previous AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+    SELECT gen_random_uuid(), chirps.id, chirps.body, COALESCE(chirps.edited_at, chirps.created_at), NOW()
+    FROM chirps
+    WHERE chirps.id = sqlc.arg('id')
+      AND chirps.user_id = sqlc.arg('user_id')
+      AND chirps.deleted_at IS NULL
+      AND (
+        sqlc.narg('edit_window_seconds')::float8 IS NULL
+        OR chirps.created_at > NOW() - make_interval(secs => sqlc.narg('edit_window_seconds')::float8)
+      )
+    FOR UPDATE
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET body = sqlc.arg('body'), edited_at = NOW(), updated_at = NOW()
+WHERE chirps.id IN (SELECT chirp_id FROM previous)
+RETURNING *;
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at ASC, id ASC;
+
+-- name: DeleteChirpRevisions :exec
+DELETE FROM chirp_revisions
+WHERE chirp_id = $1;
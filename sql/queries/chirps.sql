@@ -1,7 +1,7 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to_id)
 values (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3
 )
 RETURNING *;
 
@@ -11,7 +11,104 @@ SELECT * FROM chirps;
 -- name: GetChirpById :one
 SELECT * FROM chirps WHERE id = $1;
 
+-- name: LockChirp :one
+SELECT * FROM chirps WHERE id = $1
+FOR UPDATE;
+
 -- name: DeleteChirp :one
 DELETE FROM chirps
 WHERE id = $1 AND user_id = $2
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: TombstoneChirp :one
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, user_id)
+  AND (sqlc.narg('author_id')::uuid IS NOT NULL OR NOT user_muted(sqlc.narg('viewer_id')::uuid, user_id))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, user_id)
+  AND (sqlc.narg('author_id')::uuid IS NOT NULL OR NOT user_muted(sqlc.narg('viewer_id')::uuid, user_id))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTimelineChirps :many
+SELECT chirps.* FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg('user_id')
+  AND chirps.deleted_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.arg('user_id'), chirps.user_id)
+  AND NOT user_muted(sqlc.arg('user_id'), chirps.user_id)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: CountReplies :one
+SELECT COUNT(*) FROM chirps WHERE in_reply_to_id = $1;
+
+-- name: CountVisibleReplies :one
+SELECT COUNT(*) FROM chirps
+WHERE in_reply_to_id = sqlc.arg('chirp_id')
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+  AND NOT user_muted(sqlc.narg('viewer_id')::uuid, chirps.user_id);
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.*, 1 AS depth
+    FROM chirps AS child
+    JOIN chirps AS parent ON parent.id = child.in_reply_to_id
+    WHERE child.id = sqlc.arg('id')
+  UNION ALL
+    SELECT parent.*, ancestors.depth + 1
+    FROM ancestors
+    JOIN chirps AS parent ON parent.id = ancestors.in_reply_to_id
+    WHERE ancestors.depth < 100
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, deleted_at, like_count, rechirp_count, edited_at, search_vector,
+    users_blocked(sqlc.narg('viewer_id')::uuid, user_id) AS blocked
+FROM ancestors
+ORDER BY depth DESC;
+
+-- name: ListReplies :many
+SELECT chirps.*, (
+    SELECT COUNT(*) FROM chirps AS replies
+    WHERE replies.in_reply_to_id = chirps.id
+      AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, replies.user_id)
+      AND NOT user_muted(sqlc.narg('viewer_id')::uuid, replies.user_id)
+) AS reply_count
+FROM chirps
+WHERE chirps.in_reply_to_id = sqlc.arg('chirp_id')
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+  AND NOT user_muted(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: IsBlockedBetween :one
+SELECT users_blocked(sqlc.arg('user_id'), sqlc.arg('other_id'))::boolean AS blocked;
@@ -0,0 +1,72 @@
+-- name: LikeChirp :one
+WITH inserted AS (
+    INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT (user_id, chirp_id) DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count + (SELECT COUNT(*) FROM inserted)
+WHERE id = $2
+RETURNING like_count;
+
+-- name: UnlikeChirp :one
+WITH deleted AS (
+    DELETE FROM chirp_likes
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count - (SELECT COUNT(*) FROM deleted)
+WHERE id = $2
+RETURNING like_count;
+
+-- name: Rechirp :one
+WITH inserted AS (
+    INSERT INTO rechirps (user_id, chirp_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT (user_id, chirp_id) DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count + (SELECT COUNT(*) FROM inserted)
+WHERE id = $2
+RETURNING rechirp_count;
+
+-- name: UndoRechirp :one
+WITH deleted AS (
+    DELETE FROM rechirps
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count - (SELECT COUNT(*) FROM deleted)
+WHERE id = $2
+RETURNING rechirp_count;
+
+-- name: ListChirpLikes :many
+SELECT user_id, created_at FROM chirp_likes
+WHERE chirp_id = sqlc.arg('chirp_id')
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, user_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, user_id)
+ORDER BY created_at DESC, user_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListRechirps :many
+SELECT user_id, created_at FROM rechirps
+WHERE chirp_id = sqlc.arg('chirp_id')
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, user_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, user_id)
+ORDER BY created_at DESC, user_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListLikedChirpIDs :many
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = sqlc.arg('user_id')
+  AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
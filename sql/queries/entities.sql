@@ -0,0 +1,79 @@
+-- name: CreateChirpHashtags :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, start_index, end_index, created_at)
+SELECT sqlc.arg('chirp_id'), tag, start_index, end_index, sqlc.arg('created_at')
+FROM unnest(
+    sqlc.arg('tags')::text[],
+    sqlc.arg('start_indexes')::int[],
+    sqlc.arg('end_indexes')::int[]
+) AS entity(tag, start_index, end_index);
+
+-- name: CreateChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_index, end_index, created_at)
+SELECT sqlc.arg('chirp_id'), user_id, start_index, end_index, sqlc.arg('created_at')
+FROM unnest(
+    sqlc.arg('user_ids')::uuid[],
+    sqlc.arg('start_indexes')::int[],
+    sqlc.arg('end_indexes')::int[]
+) AS entity(user_id, start_index, end_index);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: ListHashtagsForChirps :many
+SELECT * FROM chirp_hashtags
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, start_index;
+
+-- name: ListMentionsForChirps :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, start_index;
+
+-- name: ListExistingUserIDs :many
+SELECT id FROM users
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: ListHashtagChirps :many
+SELECT chirps.* FROM chirps
+WHERE chirps.deleted_at IS NULL
+  AND EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id AND chirp_hashtags.tag = sqlc.arg('tag')
+  )
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+  AND NOT user_muted(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListMentionChirps :many
+SELECT chirps.* FROM chirps
+WHERE chirps.deleted_at IS NULL
+  AND EXISTS (
+    SELECT 1 FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = sqlc.arg('user_id')
+  )
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+  AND NOT user_muted(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: CountHashtagUsesSince :many
+SELECT tag,
+    FLOOR(EXTRACT(EPOCH FROM (NOW() - created_at)) / 60)::integer AS minutes_ago,
+    COUNT(*) AS uses
+FROM chirp_hashtags
+WHERE created_at >= NOW() - make_interval(secs => sqlc.arg('window_seconds')::float8)
+GROUP BY tag, minutes_ago;
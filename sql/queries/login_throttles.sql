@@ -0,0 +1,27 @@
+-- name: ListLoginLockouts :many
+SELECT key, locked_until::timestamp AS locked_until FROM login_throttles
+WHERE key = ANY(sqlc.arg('keys')::text[])
+  AND locked_until > NOW();
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES (sqlc.arg('key'), 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < sqlc.arg('reset_before') THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING failures;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET locked_until = sqlc.arg('locked_until')
+WHERE key = sqlc.arg('key');
+
+-- name: ClearLoginThrottles :execrows
+DELETE FROM login_throttles
+WHERE key = ANY(sqlc.arg('keys')::text[]);
+
+-- name: LockLoginKey :exec
+SELECT pg_advisory_xact_lock(hashtextextended('login:' || sqlc.arg('key')::text, 0));
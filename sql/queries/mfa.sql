@@ -0,0 +1,61 @@
+-- name: SetPendingTOTPSecret :execrows
+UPDATE users
+SET totp_secret = $2, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL;
+
+-- name: EnableTOTP :execrows
+UPDATE users
+SET totp_enabled_at = NOW(), totp_last_counter = $2, updated_at = NOW()
+WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: AdvanceTOTPCounter :execrows
+UPDATE users
+SET totp_last_counter = $2
+WHERE id = $1 AND totp_last_counter < $2;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+SELECT gen_random_uuid(), sqlc.arg('user_id'), code_hash, NOW()
+FROM unnest(sqlc.arg('code_hashes')::text[]) AS code_hash;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
+
+-- name: ListUnusedRecoveryCodes :many
+SELECT id, code_hash FROM mfa_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL
+ORDER BY created_at, id;
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = NOW()
+WHERE id = $1 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM mfa_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (token, user_id, expires_in_seconds, use_cookies, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW());
+
+-- name: LockMFAChallenge :one
+SELECT * FROM mfa_challenges
+WHERE token = $1
+FOR UPDATE;
+
+-- name: RecordMFAChallengeFailure :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token = $1
+RETURNING attempts;
+
+-- name: DeleteMFAChallenge :exec
+DELETE FROM mfa_challenges
+WHERE token = $1;
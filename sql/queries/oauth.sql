@@ -0,0 +1,118 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, user_id, name, secret_hash, redirect_uris, scopes)
+VALUES (
+    sqlc.arg('id'),
+    sqlc.arg('user_id'),
+    sqlc.arg('name'),
+    sqlc.narg('secret_hash'),
+    sqlc.arg('redirect_uris')::text[],
+    sqlc.arg('scopes')::text[]
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = sqlc.arg('id')
+  AND revoked_at IS NULL;
+
+-- name: ListOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: RevokeOAuthClient :execrows
+UPDATE oauth_clients
+SET revoked_at = NOW()
+WHERE id = sqlc.arg('id')
+  AND user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL;
+
+-- name: CreateOAuthCode :exec
+INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+    sqlc.arg('code_hash'),
+    sqlc.arg('client_id'),
+    sqlc.arg('user_id'),
+    sqlc.arg('redirect_uri'),
+    sqlc.arg('scopes')::text[],
+    sqlc.arg('code_challenge'),
+    sqlc.arg('expires_at')
+);
+
+-- name: LockOAuthCode :one
+SELECT * FROM oauth_codes
+WHERE code_hash = sqlc.arg('code_hash')
+FOR UPDATE;
+
+-- name: MarkOAuthCodeUsed :exec
+UPDATE oauth_codes
+SET used_at = NOW(), grant_id = sqlc.arg('grant_id')
+WHERE code_hash = sqlc.arg('code_hash');
+
+-- name: CreateOAuthGrant :exec
+INSERT INTO oauth_grants (id, client_id, user_id, scopes, refresh_token_hash, refresh_expires_at)
+VALUES (
+    sqlc.arg('id'),
+    sqlc.arg('client_id'),
+    sqlc.arg('user_id'),
+    sqlc.arg('scopes')::text[],
+    sqlc.arg('refresh_token_hash'),
+    sqlc.arg('refresh_expires_at')
+);
+
+-- name: GetActiveOAuthGrant :one
+SELECT * FROM oauth_grants
+WHERE id = sqlc.arg('id')
+  AND revoked_at IS NULL
+  AND refresh_expires_at > NOW()
+  AND EXISTS (
+      SELECT 1 FROM oauth_clients
+      WHERE oauth_clients.id = oauth_grants.client_id
+        AND oauth_clients.revoked_at IS NULL
+  );
+
+-- name: RotateOAuthRefreshToken :one
+UPDATE oauth_grants
+SET previous_refresh_token_hash = refresh_token_hash,
+    refresh_token_hash = sqlc.arg('new_refresh_token_hash'),
+    refresh_expires_at = sqlc.arg('refresh_expires_at'),
+    last_used_at = NOW()
+WHERE refresh_token_hash = sqlc.arg('refresh_token_hash')
+  AND client_id = sqlc.arg('client_id')
+  AND revoked_at IS NULL
+  AND refresh_expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeOAuthGrant :execrows
+UPDATE oauth_grants
+SET revoked_at = NOW()
+WHERE id = sqlc.arg('id')
+  AND client_id = sqlc.arg('client_id')
+  AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantByRefreshToken :execrows
+UPDATE oauth_grants
+SET revoked_at = NOW()
+WHERE refresh_token_hash = sqlc.arg('refresh_token_hash')
+  AND client_id = sqlc.arg('client_id')
+  AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantByPreviousRefreshToken :one
+UPDATE oauth_grants
+SET revoked_at = NOW()
+WHERE previous_refresh_token_hash = sqlc.arg('refresh_token_hash')
+  AND client_id = sqlc.arg('client_id')
+  AND revoked_at IS NULL
+RETURNING *;
+
+-- name: RevokeUserOAuthGrants :execrows
+UPDATE oauth_grants
+SET revoked_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL;
+
+-- name: DeleteUnusedOAuthCodes :exec
+DELETE FROM oauth_codes
+WHERE user_id = sqlc.arg('user_id')
+  AND used_at IS NULL;
@@ -1,12 +1,13 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (user_id, token, expires_at, created_at, updated_at)
-VALUES ($1, $2, $3, NOW(), NOW())
+INSERT INTO refresh_tokens (user_id, token, expires_at, family_id, parent_token, user_agent, ip_address, name, last_used_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW(), NOW())
 RETURNING *;
 
 -- name: GetRefreshToken :one
 SELECT * FROM refresh_tokens 
 WHERE token = $1 
   AND revoked_at IS NULL 
+  AND rotated_at IS NULL
   AND expires_at > NOW();
   
 -- name: RevokeRefreshToken :one
@@ -14,3 +15,51 @@ UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1
 RETURNING *;
+
+-- name: LockRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE;
+
+-- name: MarkRefreshTokenRotated :exec
+UPDATE refresh_tokens
+SET rotated_at = NOW(), updated_at = NOW()
+WHERE token = $1;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: ListSessions :many
+SELECT family_id, name, user_agent, ip_address, last_used_at, expires_at,
+    (SELECT MIN(family.created_at) FROM refresh_tokens AS family WHERE family.family_id = refresh_tokens.family_id)::timestamp AS started_at
+FROM refresh_tokens
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND rotated_at IS NULL
+  AND expires_at > NOW()
+ORDER BY last_used_at DESC, family_id;
+
+-- name: RenameSession :execrows
+UPDATE refresh_tokens
+SET name = sqlc.arg('name'), updated_at = NOW()
+WHERE family_id = sqlc.arg('family_id')
+  AND user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL
+  AND rotated_at IS NULL
+  AND expires_at > NOW();
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = sqlc.arg('family_id')
+  AND user_id = sqlc.arg('user_id')
+  AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+  AND family_id IS DISTINCT FROM sqlc.narg('keep_family_id')
+  AND revoked_at IS NULL;
@@ -0,0 +1,48 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, user_id, body, in_reply_to_id, attachment_ids, publish_at)
+VALUES (
+    sqlc.arg('id'),
+    sqlc.arg('user_id'),
+    sqlc.arg('body'),
+    sqlc.narg('in_reply_to_id'),
+    sqlc.arg('attachment_ids'),
+    sqlc.arg('publish_at')
+)
+RETURNING *;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC, id ASC;
+
+-- name: CountPendingScheduledChirps :one
+SELECT COUNT(*) FROM scheduled_chirps
+WHERE user_id = $1 AND failure = '';
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE failure = '' AND publish_at <= NOW()
+ORDER BY publish_at ASC, id ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: FailScheduledChirp :exec
+UPDATE scheduled_chirps
+SET failure = $1
+WHERE id = $2;
+
+-- name: LockUserPosting :exec
+SELECT pg_advisory_xact_lock(hashtextextended('posting:' || sqlc.arg('user_id')::text, 0));
+
+-- name: CountRecentChirps :one
+SELECT (
+    SELECT COUNT(*) FROM chirps
+    WHERE chirps.user_id = sqlc.arg('user_id') AND chirps.created_at > sqlc.arg('since')
+) + (
+    SELECT COUNT(*) FROM scheduled_chirps
+    WHERE scheduled_chirps.user_id = sqlc.arg('user_id') AND scheduled_chirps.created_at > sqlc.arg('since')
+) AS count;
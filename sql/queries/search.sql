@@ -0,0 +1,14 @@
+-- name: SearchChirps :many
+SELECT chirps.*,
+    ts_rank(chirps.search_vector, q)::real AS rank,
+    ts_headline('english', chirps.body, q, 'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', MaxFragments=2, MinWords=5, MaxWords=20') AS snippet
+FROM chirps, to_tsquery('english', sqlc.arg('tsquery')) AS q
+WHERE chirps.search_vector @@ q
+  AND chirps.deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+  AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since')::timestamp)
+  AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until')::timestamp)
+  AND NOT users_blocked(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+  AND NOT user_muted(sqlc.narg('viewer_id')::uuid, chirps.user_id)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
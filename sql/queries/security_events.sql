@@ -0,0 +1,3 @@
+-- name: CreateSecurityEvent :exec
+INSERT INTO security_events (id, user_id, event_type, detail, ip_address, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW());
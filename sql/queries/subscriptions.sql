@@ -0,0 +1,38 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: LockSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1
+FOR UPDATE;
+
+-- name: LockUserSubscription :exec
+SELECT pg_advisory_xact_lock(hashtextextended('subscription:' || sqlc.arg('user_id')::text, 0));
+
+-- name: SaveSubscription :one
+INSERT INTO subscriptions (user_id, plan, status, started_at, expires_at, canceled_at, ended_at, last_event_at)
+VALUES (
+    sqlc.arg('user_id'),
+    sqlc.arg('plan'),
+    sqlc.arg('status'),
+    sqlc.arg('started_at'),
+    sqlc.narg('expires_at'),
+    sqlc.narg('canceled_at'),
+    sqlc.narg('ended_at'),
+    sqlc.narg('last_event_at')
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    started_at = EXCLUDED.started_at,
+    expires_at = EXCLUDED.expires_at,
+    canceled_at = EXCLUDED.canceled_at,
+    ended_at = EXCLUDED.ended_at,
+    last_event_at = EXCLUDED.last_event_at,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: EndExpiredSubscriptions :many
+UPDATE subscriptions
+SET status = 'ended', ended_at = expires_at, updated_at = NOW()
+WHERE status <> 'ended' AND expires_at <= NOW()
+RETURNING *;
@@ -1,7 +1,7 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3
 )
 RETURNING *;
 
@@ -15,14 +15,47 @@ SELECT * FROM users WHERE email = $1;
 -- name: UpdateUser :one
 UPDATE users
 SET email = COALESCE(NULLIF($2, ''), email),
+    email_verified_at = CASE WHEN NULLIF($2, '') IS NULL OR $2 = email THEN email_verified_at END,
     hashed_password = COALESCE(NULLIF($3, ''), hashed_password),
     updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
--- name: UpgradeUserToChirpyRed :one
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: SearchUsers :many
+SELECT id, created_at, handle, display_name FROM users
+WHERE (handle ILIKE sqlc.arg('pattern')
+   OR display_name ILIKE sqlc.arg('pattern'))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = users.id AND blocked_id = sqlc.narg('viewer_id')
+  )
+ORDER BY lower(handle) ASC NULLS LAST, id ASC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
+
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE lower(handle) = lower(sqlc.arg('handle'));
+
+-- name: ListUsersByHandles :many
+SELECT id, handle FROM users
+WHERE lower(handle) = ANY(sqlc.arg('handles')::text[]);
+
+-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = TRUE,
+SET handle = COALESCE(sqlc.narg('handle'), handle),
+    display_name = COALESCE(sqlc.narg('display_name'), display_name),
+    bio = COALESCE(sqlc.narg('bio'), bio),
+    avatar_attachment_id = CASE
+        WHEN sqlc.arg('set_avatar')::boolean THEN sqlc.narg('avatar_attachment_id')
+        ELSE avatar_attachment_id
+    END,
     updated_at = NOW()
-WHERE id = $1
-RETURNING *;
\ No newline at end of file
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: RehashUserPassword :execrows
+UPDATE users
+SET hashed_password = sqlc.arg('new_hash')
+WHERE id = sqlc.arg('id')
+  AND hashed_password = sqlc.arg('old_hash');
@@ -0,0 +1,51 @@
+-- name: CreateWebAuthnChallenge :exec
+INSERT INTO webauthn_challenges (token, user_id, purpose, challenge, expires_at)
+VALUES (
+    sqlc.arg('token'),
+    sqlc.narg('user_id'),
+    sqlc.arg('purpose'),
+    sqlc.arg('challenge'),
+    sqlc.arg('expires_at')
+);
+
+-- name: ConsumeWebAuthnChallenge :one
+DELETE FROM webauthn_challenges
+WHERE token = sqlc.arg('token')
+  AND purpose = sqlc.arg('purpose')
+RETURNING *;
+
+-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials (id, user_id, public_key, sign_count, name)
+VALUES (
+    sqlc.arg('id'),
+    sqlc.arg('user_id'),
+    sqlc.arg('public_key'),
+    sqlc.arg('sign_count'),
+    sqlc.arg('name')
+)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: LockWebAuthnCredential :one
+SELECT * FROM webauthn_credentials
+WHERE id = sqlc.arg('id')
+FOR UPDATE;
+
+-- name: UpdateWebAuthnSignCount :exec
+UPDATE webauthn_credentials
+SET sign_count = sqlc.arg('sign_count'), last_used_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: ListWebAuthnCredentials :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = sqlc.arg('user_id')
+ORDER BY created_at;
+
+-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM webauthn_credentials
+WHERE id = sqlc.arg('id')
+  AND user_id = sqlc.arg('user_id');
+
+-- name: DeleteExpiredWebAuthnChallenges :exec
+DELETE FROM webauthn_challenges
+WHERE expires_at < NOW();
@@ -0,0 +1,27 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, source, event_id, event_type, payload, status)
+VALUES (
+    sqlc.arg('id'),
+    sqlc.arg('source'),
+    sqlc.arg('event_id'),
+    sqlc.arg('event_type'),
+    sqlc.arg('payload'),
+    'received'
+)
+ON CONFLICT (source, event_id) DO NOTHING
+RETURNING *;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events
+SET status = sqlc.arg('status'), detail = sqlc.arg('detail'), processed_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (received_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX chirps_in_reply_to_id_created_at_id_idx ON chirps (in_reply_to_id, created_at, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_in_reply_to_id_created_at_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS deleted_at,
+DROP COLUMN IF EXISTS in_reply_to_id;
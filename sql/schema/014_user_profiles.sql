@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN handle VARCHAR(30),
+    ADD COLUMN display_name VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN bio VARCHAR(160) NOT NULL DEFAULT '',
+    ADD COLUMN avatar_attachment_id UUID REFERENCES attachments(id) ON DELETE SET NULL;
+
+-- Handles keep the case they were chosen in but are unique ignoring case.
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (lower(handle));
+
+-- +goose Down
+DROP INDEX IF EXISTS users_handle_lower_idx;
+ALTER TABLE users
+    DROP COLUMN avatar_attachment_id,
+    DROP COLUMN bio,
+    DROP COLUMN display_name,
+    DROP COLUMN handle;
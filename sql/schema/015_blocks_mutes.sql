@@ -0,0 +1,46 @@
+-- +goose Up
+CREATE TABLE blocks (
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX blocks_blocked_id_idx ON blocks (blocked_id);
+
+CREATE TABLE mutes (
+    muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
+
+-- users_blocked is true when either user has blocked the other. A NULL
+-- viewer (an anonymous reader) is never blocked.
+-- +goose StatementBegin
+CREATE FUNCTION users_blocked(a UUID, b UUID) RETURNS BOOLEAN
+LANGUAGE SQL STABLE AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocker_id = a AND blocked_id = b)
+           OR (blocker_id = b AND blocked_id = a)
+    )
+$$;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION user_muted(viewer UUID, author UUID) RETURNS BOOLEAN
+LANGUAGE SQL STABLE AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM mutes WHERE muter_id = viewer AND muted_id = author
+    )
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION user_muted(UUID, UUID);
+DROP FUNCTION users_blocked(UUID, UUID);
+DROP TABLE mutes;
+DROP TABLE blocks;
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Accounts from before verification existed are treated as verified, so
+-- REQUIRE_VERIFIED_EMAIL doesn't lock them all out.
+UPDATE users SET email_verified_at = created_at;
+
+-- Tokens are stored as SHA-256 hashes so a database leak doesn't hand out
+-- working reset links. email is the address the token was mailed to; a
+-- verification token only verifies that address.
+CREATE TABLE email_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    email TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX email_tokens_user_id_purpose_idx ON email_tokens (user_id, purpose);
+
+-- +goose Down
+DROP TABLE email_tokens;
+ALTER TABLE users DROP COLUMN email_verified_at;
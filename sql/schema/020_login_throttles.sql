@@ -0,0 +1,13 @@
+-- +goose Up
+-- One row per throttle key: an email address ("account:...") or a client
+-- address ("ip:..."). Rows for unknown emails are kept too, so lockouts
+-- don't reveal which addresses have accounts.
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE login_throttles;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Personal API tokens are long-lived credentials for bots and integrations.
+-- Only a hash of the token is stored; token_prefix keeps enough of it for
+-- the owner to tell their tokens apart.
+CREATE TABLE api_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    token_prefix TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX api_tokens_user_id_idx ON api_tokens (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE api_tokens;
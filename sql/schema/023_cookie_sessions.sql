@@ -0,0 +1,7 @@
+-- +goose Up
+-- Whether a login waiting on its second factor asked for cookies instead of
+-- tokens in the response body.
+ALTER TABLE mfa_challenges ADD COLUMN use_cookies BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE mfa_challenges DROP COLUMN use_cookies;
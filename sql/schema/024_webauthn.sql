@@ -0,0 +1,30 @@
+-- +goose Up
+-- Passkeys. The ID is the credential ID the authenticator chose, and the
+-- public key is kept in its COSE encoding. sign_count is the authenticator's
+-- signature counter as of the last login, zero for those that don't keep one.
+CREATE TABLE webauthn_credentials (
+    id BYTEA PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    name TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX webauthn_credentials_user_id_idx ON webauthn_credentials (user_id, created_at);
+
+-- Challenges issued for registration and login ceremonies. Each is deleted
+-- when it is used. Login challenges have no user: the passkey says whose it is.
+CREATE TABLE webauthn_challenges (
+    token TEXT PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL,
+    challenge BYTEA NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE webauthn_challenges;
+DROP TABLE webauthn_credentials;
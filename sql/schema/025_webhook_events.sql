@@ -0,0 +1,22 @@
+-- +goose Up
+-- Every verified webhook delivery, kept so a redelivered or replayed event
+-- is recognised by its ID and not applied twice, and so admins can see what
+-- came in. status is received while the event is being applied, then
+-- processed, ignored (an event type Chirpy doesn't act on) or failed.
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    source TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMP,
+    UNIQUE (source, event_id)
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- A user's Chirpy Red subscription, kept up to date from Polka's events.
+-- Whether a user is Chirpy Red is worked out from this row rather than
+-- stored on the user. status is active, past_due (a renewal payment failed
+-- and Polka is retrying), canceled (runs until expires_at, then ends) or
+-- ended. A NULL expires_at never lapses on its own.
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP,
+    canceled_at TIMESTAMP,
+    ended_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX subscriptions_expires_at_idx ON subscriptions (expires_at)
+    WHERE status <> 'ended';
+
+-- Upgrades received before subscriptions were tracked had no end date.
+INSERT INTO subscriptions (user_id, plan, status, started_at)
+SELECT id, 'chirpy_red', 'active', updated_at FROM users WHERE is_chirpy_red;
+
+ALTER TABLE users DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET is_chirpy_red = TRUE
+WHERE id IN (
+    SELECT user_id FROM subscriptions
+    WHERE status <> 'ended' AND (expires_at IS NULL OR expires_at > NOW())
+);
+
+DROP TABLE subscriptions;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Chirps waiting to be posted. When publish_at comes round the chirp is
+-- created as if posted then and the row is deleted. If it can never be
+-- posted, for instance because its media has gone, failure says why and the
+-- row stays until its owner deletes it.
+CREATE TABLE scheduled_chirps (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    in_reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    attachment_ids UUID[] NOT NULL DEFAULT '{}',
+    publish_at TIMESTAMP NOT NULL,
+    failure TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX scheduled_chirps_due_idx ON scheduled_chirps (publish_at) WHERE failure = '';
+CREATE INDEX scheduled_chirps_user_id_idx ON scheduled_chirps (user_id, publish_at, id);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- last_event_at is when the newest Polka event applied to the subscription
+-- happened, so one that was delayed in delivery can't overwrite a later
+-- change. NULL until the first event with a time is applied.
+ALTER TABLE subscriptions ADD COLUMN last_event_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN last_event_at;
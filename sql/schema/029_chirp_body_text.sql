@@ -0,0 +1,25 @@
+-- +goose Up
+-- Chirpy Red chirps can be far longer than the 120 characters body was
+-- created with, and the limits now live in the entitlements table instead.
+-- search_vector is generated from body, so it has to be rebuilt around the
+-- change of type.
+DROP INDEX chirps_search_vector_idx;
+ALTER TABLE chirps DROP COLUMN search_vector;
+
+ALTER TABLE chirps ALTER COLUMN body TYPE TEXT;
+
+ALTER TABLE chirps
+ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX chirps_search_vector_idx ON chirps USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX chirps_search_vector_idx;
+ALTER TABLE chirps DROP COLUMN search_vector;
+
+ALTER TABLE chirps ALTER COLUMN body TYPE VARCHAR(120);
+
+ALTER TABLE chirps
+ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX chirps_search_vector_idx ON chirps USING GIN (search_vector);